@@ -0,0 +1,205 @@
+package backup
+
+import (
+	"bruce/random"
+	"bruce/system"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// indexFile is written in each run directory and lists the files backed up during the run.
+const indexFile = "index.json"
+
+var (
+	run     = &Run{}
+	runLock = new(sync.Mutex)
+)
+
+// Entry is a file that was modified during the run, Created is set when the file did not exist beforehand.
+type Entry struct {
+	Path    string      `json:"path"`
+	Backup  string      `json:"backup,omitempty"`
+	Mode    fs.FileMode `json:"mode,omitempty"`
+	Created bool        `json:"created,omitempty"`
+}
+
+// Index describes the backups taken during a single run.
+type Index struct {
+	RunID   string    `json:"runId"`
+	Source  string    `json:"source,omitempty"`
+	Started time.Time `json:"started"`
+	Entries []Entry   `json:"entries"`
+}
+
+// Run tracks the original content of every file changed during a single run so the changes can be rolled back.
+type Run struct {
+	Index
+	Root  string
+	saved map[string]bool
+}
+
+// DefaultDir returns the backup directory used when none is configured.
+func DefaultDir() string {
+	return filepath.Join(system.StateDir(), "backups")
+}
+
+// Begin starts tracking a new run with backups kept in root under the run id, entries from a previous run are
+// forgotten. When root is empty a temporary directory is used once the first backup is made.
+func Begin(root, runID, source string) {
+	runLock.Lock()
+	defer runLock.Unlock()
+	run = &Run{Index: Index{RunID: runID, Source: source, Started: time.Now()}, Root: root, saved: make(map[string]bool)}
+	log.Debug().Msgf("using backup directory as: %s", run.dir())
+}
+
+// dir returns the directory holding the backups of the run.
+func (r *Run) dir() string {
+	return filepath.Join(r.Root, r.RunID)
+}
+
+// Dir returns the backup directory of the current run.
+func Dir() string {
+	runLock.Lock()
+	defer runLock.Unlock()
+	return run.dir()
+}
+
+// FileName returns the location of the backup for src within the backup directory of the current run.
+func FileName(src string) string {
+	return fileName(Dir(), src)
+}
+
+func fileName(dir, src string) string {
+	src = filepath.Clean(src)
+	// windows paths contain a volume name which cannot be part of a path within the backup directory
+	src = strings.TrimPrefix(src, filepath.VolumeName(src))
+	return filepath.Join(dir, "files", strings.TrimLeft(src, string(os.PathSeparator)))
+}
+
+// Save records the original state of a file before it is modified, only the first save of a path in a run is kept
+// so a rollback always returns to the state before the run.
+func Save(ctx context.Context, src string) error {
+	if abs, err := filepath.Abs(src); err == nil {
+		src = abs
+	}
+	runLock.Lock()
+	defer runLock.Unlock()
+	if run.saved == nil {
+		run.saved = make(map[string]bool)
+	}
+	if run.saved[src] {
+		return nil
+	}
+	if run.Root == "" {
+		run.Root = filepath.Join(os.TempDir(), "bruce-backup-"+random.String(12))
+	}
+	fi, err := os.Stat(src)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.Ctx(ctx).Debug().Msgf("no existing file to back up, tracking as created: %s", src)
+		run.Entries = append(run.Entries, Entry{Path: src, Created: true})
+	case err != nil:
+		return err
+	case fi.IsDir():
+		return fmt.Errorf("cannot back up directory: %s", src)
+	default:
+		dst := fileName(run.dir(), src)
+		if err := copyFile(src, dst, fi.Mode().Perm()); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("could not create backup for: %s", src)
+			return err
+		}
+		log.Ctx(ctx).Debug().Msgf("backed up %s to: %s", src, dst)
+		run.Entries = append(run.Entries, Entry{Path: src, Backup: dst, Mode: fi.Mode().Perm()})
+	}
+	run.saved[src] = true
+	return run.writeIndex()
+}
+
+// writeIndex saves the run index alongside the backups so they can be listed and restored after the run.
+func (r *Run) writeIndex() error {
+	d, err := json.MarshalIndent(r.Index, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.dir(), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.dir(), indexFile), d, 0600)
+}
+
+// Entries returns the files modified during the current run in the order they were first changed.
+func Entries() []Entry {
+	runLock.Lock()
+	defer runLock.Unlock()
+	return append([]Entry{}, run.Entries...)
+}
+
+// Rollback restores every file changed during the run from its backup and removes files the run created.
+// Files are restored in reverse order and the restored paths are returned, errors do not stop the remaining restores.
+func Rollback() ([]string, error) {
+	runLock.Lock()
+	defer runLock.Unlock()
+	var restored []string
+	var errs []error
+	for i := len(run.Entries) - 1; i >= 0; i-- {
+		e := run.Entries[i]
+		if err := restore(e); err != nil {
+			log.Error().Err(err).Msgf("rollback failed for: %s", e.Path)
+			errs = append(errs, fmt.Errorf("%s: %w", e.Path, err))
+			continue
+		}
+		restored = append(restored, e.Path)
+	}
+	run.Entries = nil
+	run.saved = make(map[string]bool)
+	return restored, errors.Join(errs...)
+}
+
+func restore(e Entry) error {
+	if e.Created {
+		log.Info().Msgf("removing file created during the run: %s", e.Path)
+		if err := os.Remove(e.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	log.Info().Msgf("restoring: %s", e.Path)
+	return copyFile(e.Backup, e.Path, e.Mode)
+}
+
+// copyFile copies src to dst through a temporary file so dst is replaced in a single rename.
+func copyFile(src, dst string, perm fs.FileMode) error {
+	d, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(d); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
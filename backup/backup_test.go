@@ -0,0 +1,103 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRollback(t *testing.T) {
+	dir := t.TempDir()
+	Begin(filepath.Join(dir, "backups"), "run", "manifest.yml")
+
+	existing := filepath.Join(dir, "etc", "app.conf")
+	created := filepath.Join(dir, "etc", "new.conf")
+	if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(existing, []byte("original"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{existing, created} {
+		if err := Save(context.Background(), p); err != nil {
+			t.Fatalf("Save(%s) error = %v", p, err)
+		}
+	}
+	os.WriteFile(existing, []byte("changed"), 0640)
+	// a second save within the run must keep the original content
+	if err := Save(context.Background(), existing); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	os.WriteFile(existing, []byte("changed again"), 0640)
+	os.WriteFile(created, []byte("new"), 0644)
+
+	if got := len(Entries()); got != 2 {
+		t.Fatalf("Entries() = %d, want 2", got)
+	}
+	restored, err := Rollback()
+	if err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if len(restored) != 2 {
+		t.Errorf("Rollback() restored = %v, want 2 files", restored)
+	}
+	d, _ := os.ReadFile(existing)
+	if string(d) != "original" {
+		t.Errorf("Rollback() content = %q, want original", d)
+	}
+	if fi, err := os.Stat(existing); err != nil || fi.Mode().Perm() != 0640 {
+		t.Errorf("Rollback() mode = %v, want 0640", fi.Mode().Perm())
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("Rollback() should remove files created during the run")
+	}
+}
+
+func TestRestoreAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "backups")
+	target := filepath.Join(dir, "app.conf")
+
+	for i, content := range []string{"v1", "v2", "v3"} {
+		os.WriteFile(target, []byte(content), 0644)
+		Begin(root, fmt.Sprintf("run-%d", i), "manifest.yml")
+		if err := Save(context.Background(), target); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		// keep the runs ordered even on coarse clocks
+		run.Started = time.Now().Add(time.Duration(i) * time.Second)
+		run.writeIndex()
+	}
+	os.WriteFile(target, []byte("v4"), 0644)
+
+	runs, err := List(root)
+	if err != nil || len(runs) != 3 || runs[0].RunID != "run-2" {
+		t.Fatalf("List() = %v, %v", runs, err)
+	}
+	if idx, err := Restore(root, target, ""); err != nil || idx.RunID != "run-2" {
+		t.Fatalf("Restore() latest = %v, %v", idx, err)
+	}
+	if d, _ := os.ReadFile(target); string(d) != "v3" {
+		t.Errorf("Restore() latest content = %q, want v3", d)
+	}
+	if _, err := Restore(root, target, "run-0"); err != nil {
+		t.Fatalf("Restore() by run error = %v", err)
+	}
+	if d, _ := os.ReadFile(target); string(d) != "v1" {
+		t.Errorf("Restore() by run content = %q, want v1", d)
+	}
+	if _, err := Restore(root, target, "missing"); err == nil {
+		t.Errorf("Restore() should fail for an unknown run")
+	}
+
+	if err := Prune(root, 2, 0); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if runs, _ := List(root); len(runs) != 2 || runs[1].RunID != "run-1" {
+		t.Errorf("Prune() kept %v, want run-2 and run-1", runs)
+	}
+}
@@ -0,0 +1,103 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// List returns the index of every run with backups in root, newest first.
+func List(root string) ([]Index, error) {
+	dirs, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []Index
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		idx, err := readIndex(filepath.Join(root, d.Name()))
+		if err != nil {
+			log.Debug().Err(err).Msgf("skipping backup directory without an index: %s", d.Name())
+			continue
+		}
+		// the directory name is the run id used for restores and pruning
+		idx.RunID = d.Name()
+		runs = append(runs, *idx)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Started.After(runs[j].Started) })
+	return runs, nil
+}
+
+func readIndex(dir string) (*Index, error) {
+	d, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if err != nil {
+		return nil, err
+	}
+	idx := &Index{}
+	if err := json.Unmarshal(d, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Restore returns path to the state it had before the run, using the most recent run that changed it when runID is
+// empty. The index of the run that was restored from is returned.
+func Restore(root, path, runID string) (*Index, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := List(root)
+	if err != nil {
+		return nil, err
+	}
+	for i := range runs {
+		if runID != "" && runs[i].RunID != runID {
+			continue
+		}
+		for _, e := range runs[i].Entries {
+			if e.Path == path {
+				return &runs[i], restore(e)
+			}
+		}
+		if runID != "" {
+			return nil, fmt.Errorf("run %s has no backup of %s", runID, path)
+		}
+	}
+	if runID != "" {
+		return nil, fmt.Errorf("no backups found for run: %s", runID)
+	}
+	return nil, fmt.Errorf("no backups found for: %s", path)
+}
+
+// Prune removes the oldest runs from root so at most keep runs remain, runs older than maxAge are removed as well.
+// A keep below zero keeps every run and a maxAge of zero disables the age limit.
+func Prune(root string, keep int, maxAge time.Duration) error {
+	runs, err := List(root)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, r := range runs {
+		expired := maxAge > 0 && time.Since(r.Started) > maxAge
+		if (keep < 0 || i < keep) && !expired {
+			continue
+		}
+		log.Debug().Msgf("removing backups of run: %s", r.RunID)
+		if err := os.RemoveAll(filepath.Join(root, r.RunID)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,125 @@
+// Package changes records what the steps of a single run changed so later steps, handlers and reports can react to it.
+package changes
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type contextKey struct{}
+
+// Change is a single path or resource altered by a step. Resources other than files are written as kind:name,
+// eg: package:nginx or service:nginx.
+type Change struct {
+	Step     int    `json:"step,omitempty"`
+	Handler  string `json:"handler,omitempty"`
+	Resource string `json:"resource"`
+}
+
+// Resource returns the reference of a non file resource, eg: Resource("package", "nginx") is package:nginx.
+func Resource(kind, name string) string {
+	return kind + ":" + name
+}
+
+// Registry holds the changes of a run, it is safe to use from steps running in parallel.
+type Registry struct {
+	mu      sync.RWMutex
+	changes []Change
+}
+
+// New creates an empty registry.
+func New() *Registry {
+	return &Registry{}
+}
+
+// WithRegistry returns a copy of ctx carrying r.
+func WithRegistry(ctx context.Context, r *Registry) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the registry of ctx, an empty registry is returned when there is none.
+func FromContext(ctx context.Context) *Registry {
+	if r, ok := ctx.Value(contextKey{}).(*Registry); ok {
+		return r
+	}
+	return New()
+}
+
+// Record adds the resources changed by the step with the given (1 based) index.
+func (r *Registry) Record(step int, resources ...string) {
+	for _, res := range resources {
+		r.Add(Change{Step: step, Resource: res})
+	}
+}
+
+// RecordHandler adds the resources changed by the named handler.
+func (r *Registry) RecordHandler(handler string, resources ...string) {
+	for _, res := range resources {
+		r.Add(Change{Handler: handler, Resource: res})
+	}
+}
+
+// Add adds previously recorded changes, eg: when resuming a run from its checkpoint.
+func (r *Registry) Add(cs ...Change) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, cs...)
+}
+
+// All returns a copy of every recorded change in the order they were recorded.
+func (r *Registry) All() []Change {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Change(nil), r.changes...)
+}
+
+// Step returns the resources changed by the step with the given index.
+func (r *Registry) Step(step int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var res []string
+	for _, c := range r.changes {
+		if c.Step == step && len(c.Handler) == 0 {
+			res = append(res, c.Resource)
+		}
+	}
+	return res
+}
+
+// Changed returns true if ref was changed during the run. A reference ending in a path separator is a directory and
+// matches every change below it, eg: /etc/nginx/ is changed when /etc/nginx/nginx.conf was written.
+func (r *Registry) Changed(ref string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.changes {
+		if matches(ref, c.Resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// Under returns true if any path below one of the directories was changed during the run.
+func (r *Registry) Under(dirs ...string) bool {
+	for _, d := range dirs {
+		if !strings.HasSuffix(d, "/") && !strings.HasSuffix(d, string(filepath.Separator)) {
+			d += string(filepath.Separator)
+		}
+		if r.Changed(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(ref, resource string) bool {
+	if ref == resource {
+		return true
+	}
+	if strings.HasSuffix(ref, "/") || strings.HasSuffix(ref, string(filepath.Separator)) {
+		return strings.HasPrefix(resource, ref) || resource == strings.TrimRight(ref, `/\`)
+	}
+	return false
+}
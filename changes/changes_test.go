@@ -0,0 +1,51 @@
+package changes
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRegistryChanged(t *testing.T) {
+	r := New()
+	r.Record(1, "/etc/nginx/nginx.conf", Resource("package", "nginx"))
+	r.Record(2, "/opt/app")
+	r.RecordHandler("reload nginx", Resource("service", "nginx"))
+	tests := []struct {
+		name string
+		ref  string
+		want bool
+	}{
+		{name: "file", ref: "/etc/nginx/nginx.conf", want: true},
+		{name: "directory", ref: "/etc/nginx/", want: true},
+		{name: "changed directory", ref: "/opt/app/", want: true},
+		{name: "directory without separator", ref: "/etc/nginx", want: false},
+		{name: "package", ref: "package:nginx", want: true},
+		{name: "handler service", ref: "service:nginx", want: true},
+		{name: "unchanged", ref: "/etc/hosts", want: false},
+		{name: "other package", ref: "package:curl", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Changed(tt.ref); got != tt.want {
+				t.Errorf("Changed(%q) = %v, want %v", tt.ref, got, tt.want)
+			}
+		})
+	}
+	if got := r.Step(1); len(got) != 2 {
+		t.Errorf("Step(1) = %v, want 2 changes", got)
+	}
+	if !r.Under("/etc") || r.Under("/usr/lib/systemd") {
+		t.Errorf("Under() did not match the changes below the directories")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	r := New()
+	r.Record(1, "/etc/app.conf")
+	if !FromContext(WithRegistry(context.Background(), r)).Changed("/etc/app.conf") {
+		t.Errorf("FromContext() did not return the run registry")
+	}
+	if FromContext(context.Background()).Changed("/etc/app.conf") {
+		t.Errorf("FromContext() without a registry should not share changes")
+	}
+}
@@ -3,11 +3,15 @@ package main
 import (
 	"bruce/config"
 	"bruce/handlers"
+	"bruce/logging"
+	"bruce/operators"
 	"bruce/system"
+	"fmt"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -17,10 +21,77 @@ var (
 
 func setLogger() {
 	zerolog.TimeFieldFormat = time.RFC3339Nano
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
+	logging.Setup(logging.Options{})
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 }
 
+// logOptions returns the logging options from the global log flags.
+func logOptions(cCtx *cli.Context) logging.Options {
+	return logging.Options{
+		Format:     cCtx.String("log-format"),
+		File:       cCtx.String("log-file"),
+		MaxSize:    cCtx.Int("log-max-size"),
+		MaxBackups: cCtx.Int("log-max-backups"),
+		Syslog:     cCtx.Bool("syslog"),
+	}
+}
+
+// setDryRun enables check mode on the operators when --dry-run is provided.
+func setDryRun(cCtx *cli.Context) {
+	if cCtx.Bool("dry-run") {
+		log.Info().Msg("dry run enabled, no changes will be made")
+		operators.SetDryRun(true)
+	}
+}
+
+// install loads the manifest and runs it with the install flags, exiting when the install fails.
+func install(cCtx *cli.Context, manifest string) {
+	t, err := config.LoadConfig(manifest)
+	if err != nil {
+		log.Error().Err(err).Msg("cannot continue without configuration data")
+		os.Exit(1)
+	}
+	if cCtx.Bool("rollback") {
+		t.Rollback = true
+	}
+	if len(cCtx.String("backup-dir")) > 0 {
+		t.BackupDir = cCtx.String("backup-dir")
+	}
+	opts := handlers.RunOptions{
+		PropertyFile: cCtx.String("property-file"),
+		ReportFile:   cCtx.String("report"),
+		Resume:       cCtx.Bool("resume"),
+		LockMode:     cCtx.String("lock"),
+		Workers:      cCtx.Int("workers"),
+		Filter: handlers.StepFilter{
+			Tags:     cCtx.StringSlice("tags"),
+			SkipTags: cCtx.StringSlice("skip-tags"),
+			StartAt:  cCtx.String("start-at-step"),
+			Names:    cCtx.StringSlice("step-name"),
+		},
+	}
+	if _, err := handlers.Install(t, opts); err != nil {
+		os.Exit(1)
+	}
+}
+
+// restoreArgs returns the path and run id given to restore. Flags given after the path are not parsed by the cli
+// package so a trailing --run is read from the arguments, any other trailing argument is refused.
+func restoreArgs(cCtx *cli.Context) (string, string, error) {
+	runID := cCtx.String("run")
+	args := cCtx.Args().Tail()
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && strings.HasPrefix(args[0], "--run="):
+		runID = strings.TrimPrefix(args[0], "--run=")
+	case len(args) == 2 && (args[0] == "--run" || args[0] == "-run"):
+		runID = args[1]
+	default:
+		return "", "", fmt.Errorf("unexpected arguments after the path: %s", strings.Join(args, " "))
+	}
+	return cCtx.Args().First(), runID, nil
+}
+
 func main() {
 	setLogger()
 	err := system.InitializeSysInfo()
@@ -28,7 +99,16 @@ func main() {
 		log.Error().Err(err).Msg("cannot start with unknown system info")
 		os.Exit(1)
 	}
-	app := &cli.App{
+	log.Debug().Msgf("Starting Bruce (Version: %s)", version)
+	err = newApp().Run(os.Args)
+	if err != nil {
+		log.Fatal().Err(err).Msg("bruce could not complete")
+	}
+}
+
+// newApp returns the bruce command line application.
+func newApp() *cli.App {
+	return &cli.App{
 		Name:  "bruce",
 		Usage: "Start with: /path/to/bruce https://someinstallhost/installme.yml",
 		Flags: []cli.Flag{
@@ -41,7 +121,82 @@ func main() {
 				Name:    "property-file",
 				Aliases: []string{"p"},
 				Value:   "",
-				Usage:   "Loads properties from a file, eg: /etc/bruce/properties.yml to be used as environment variables for operators and templates",
+				Usage:   "Loads properties from a YAML, JSON or TOML file, eg: /etc/bruce/properties.yml to be used as environment variables for operators and templates",
+			},
+			&cli.StringFlag{
+				Name:  "report",
+				Value: "",
+				Usage: "Writes a JSON run report to the given file (or - for stdout) once the run completes",
+			},
+			&cli.BoolFlag{
+				Name:  "resume",
+				Value: false,
+				Usage: "Continue a failed install from the failing step, refused if the manifest changed since it failed",
+			},
+			&cli.BoolFlag{
+				Name:  "rollback",
+				Value: false,
+				Usage: "Restore every file changed during the run from its backup when a step fails",
+			},
+			&cli.StringFlag{
+				Name:  "backup-dir",
+				Value: "",
+				Usage: "Directory to keep file backups in, defaults to /var/lib/bruce/backups (or ~/.bruce/backups when not root)",
+			},
+			&cli.StringFlag{
+				Name:  "lock",
+				Value: "wait",
+				Usage: "What to do when another run holds the host wide run lock: wait, skip or fail",
+			},
+			&cli.StringSliceFlag{
+				Name:  "tags",
+				Usage: "Only run the steps tagged with one of these tags, eg: --tags packages,config",
+			},
+			&cli.StringSliceFlag{
+				Name:  "skip-tags",
+				Usage: "Skip the steps tagged with any of these tags",
+			},
+			&cli.StringFlag{
+				Name:  "start-at-step",
+				Usage: "Skip the steps before this step, given as a step number, id or name",
+			},
+			&cli.StringSliceFlag{
+				Name:  "step-name",
+				Usage: "Only run the steps with this name, may be repeated",
+			},
+			&cli.IntFlag{
+				Name:  "workers",
+				Usage: "Maximum number of steps run in parallel, overrides the manifest workers (default 1)",
+			},
+			&cli.IntFlag{
+				Name:  "history-keep",
+				Value: 100,
+				Usage: "Number of runs kept in the local run history, see: bruce history",
+			},
+			&cli.StringFlag{
+				Name:  "log-format",
+				Usage: "Log output format: console (default) or json",
+			},
+			&cli.StringFlag{
+				Name:  "log-file",
+				Usage: "Also write the logs to this file, it is rotated once it reaches --log-max-size",
+			},
+			&cli.IntFlag{
+				Name:  "log-max-size",
+				Usage: "Size in megabytes at which the log file is rotated (default 10)",
+			},
+			&cli.IntFlag{
+				Name:  "log-max-backups",
+				Usage: "Number of rotated log files to keep (default 5)",
+			},
+			&cli.BoolFlag{
+				Name:  "syslog",
+				Usage: "Also send the logs to the local syslog daemon",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Value: false,
+				Usage: "Report what each step would change without altering the system",
 			},
 			&cli.BoolFlag{
 				Name:    "debug",
@@ -54,23 +209,18 @@ func main() {
 			if cCtx.Bool("debug") {
 				zerolog.SetGlobalLevel(zerolog.DebugLevel)
 			}
+			setDryRun(cCtx)
 			if cCtx.Args().First() != "" {
-				t, err := config.LoadConfig(cCtx.Args().First())
-				if err != nil {
-					log.Error().Err(err).Msg("cannot continue without configuration data")
-					os.Exit(1)
-				}
-				handlers.Install(t, cCtx.String("property-file"))
+				install(cCtx, cCtx.Args().First())
 				return nil
 			}
-			t, err := config.LoadConfig(cCtx.String("config"))
-			if err != nil {
-				log.Error().Err(err).Msg("cannot continue without configuration data")
-				os.Exit(1)
-			}
-			handlers.Install(t, cCtx.String("property-file"))
+			install(cCtx, cCtx.String("config"))
 			return nil
 		},
+		Before: func(cCtx *cli.Context) error {
+			handlers.SetHistoryKeep(cCtx.Int("history-keep"))
+			return logging.Setup(logOptions(cCtx))
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "install",
@@ -80,12 +230,8 @@ func main() {
 					if cCtx.Bool("debug") {
 						zerolog.SetGlobalLevel(zerolog.DebugLevel)
 					}
-					t, err := config.LoadConfig(cCtx.String("config"))
-					if err != nil {
-						log.Error().Err(err).Msg("cannot continue without configuration data")
-						os.Exit(1)
-					}
-					handlers.Install(t, cCtx.String("property-file"))
+					setDryRun(cCtx)
+					install(cCtx, cCtx.String("config"))
 					return nil
 				},
 			},
@@ -97,8 +243,8 @@ func main() {
 					if cCtx.Bool("debug") {
 						zerolog.SetGlobalLevel(zerolog.DebugLevel)
 					}
-
-					handlers.RunServer(cCtx.Args().First())
+					setDryRun(cCtx)
+					handlers.RunServer(cCtx.Args().First(), logOptions(cCtx))
 					return nil
 				},
 			},
@@ -114,6 +260,106 @@ func main() {
 					return nil
 				},
 			},
+			{
+				Name:  "backups",
+				Usage: "manage the file backups taken before templates, copies and cron files are changed",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "lists the runs with backups and the files they changed",
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.Bool("debug") {
+								zerolog.SetGlobalLevel(zerolog.DebugLevel)
+							}
+							if err := handlers.ListBackups(cCtx.String("backup-dir")); err != nil {
+								os.Exit(1)
+							}
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:      "restore",
+				Usage:     "restores a single file to the state it had before the most recent run that changed it",
+				ArgsUsage: "<path> [--run <run id>]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "run",
+						Value: "",
+						Usage: "Restore from the backups of a specific run id, see: bruce backups list",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Bool("debug") {
+						zerolog.SetGlobalLevel(zerolog.DebugLevel)
+					}
+					path, runID, err := restoreArgs(cCtx)
+					if err != nil {
+						log.Error().Err(err).Msg("cannot restore")
+						os.Exit(1)
+					}
+					if err := handlers.RestoreBackup(cCtx.String("backup-dir"), path, runID); err != nil {
+						os.Exit(1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "history",
+				Usage: "lists the runs recorded on this host by installs and the server",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "limit",
+						Value: 20,
+						Usage: "Number of runs to list, 0 lists every recorded run",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Bool("debug") {
+						zerolog.SetGlobalLevel(zerolog.DebugLevel)
+					}
+					if err := handlers.History(cCtx.Int("limit")); err != nil {
+						os.Exit(1)
+					}
+					return nil
+				},
+				Subcommands: []*cli.Command{
+					{
+						Name:      "show",
+						Usage:     "shows the steps, outcomes and errors of a recorded run",
+						ArgsUsage: "<run id>",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "Print the recorded run report as JSON",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.Bool("debug") {
+								zerolog.SetGlobalLevel(zerolog.DebugLevel)
+							}
+							if err := handlers.ShowHistory(cCtx.Args().First(), cCtx.Bool("json")); err != nil {
+								os.Exit(1)
+							}
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "status",
+				Usage: "shows the run currently holding the host wide run lock",
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Bool("debug") {
+						zerolog.SetGlobalLevel(zerolog.DebugLevel)
+					}
+					if err := handlers.Status(); err != nil {
+						os.Exit(1)
+					}
+					return nil
+				},
+			},
 			{
 				Name:  "upgrade",
 				Usage: "this command will upgrade the bruce application to the latest version",
@@ -138,10 +384,4 @@ func main() {
 			},
 		},
 	}
-	log.Debug().Msgf("Starting Bruce (Version: %s)", version)
-	err = app.Run(os.Args)
-	if err != nil {
-		log.Fatal().Err(err)
-	}
-
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bruce/backup"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestoreCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "newest run", args: []string{}, want: "v2"},
+		{name: "run flag before the path", args: []string{"--run", "run-1"}, want: "v1"},
+		{name: "run flag after the path", args: []string{"", "--run", "run-1"}, want: "v1"},
+		{name: "run flag with value after the path", args: []string{"", "--run=run-1"}, want: "v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			root, target := filepath.Join(dir, "backups"), filepath.Join(dir, "app.conf")
+			for _, run := range []struct{ id, content string }{{"run-1", "v1"}, {"run-2", "v2"}} {
+				if err := os.WriteFile(target, []byte(run.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				backup.Begin(root, run.id, "manifest.yml")
+				if err := backup.Save(context.Background(), target); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.WriteFile(target, []byte("v3"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			// an empty argument marks where the path goes, without one the path is the last argument
+			args := []string{"bruce", "--backup-dir", root, "restore"}
+			placed := false
+			for _, a := range tt.args {
+				if a == "" {
+					a, placed = target, true
+				}
+				args = append(args, a)
+			}
+			if !placed {
+				args = append(args, target)
+			}
+			if err := newApp().Run(args); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if d, _ := os.ReadFile(target); string(d) != tt.want {
+				t.Errorf("restore %v content = %q, want %q", args[1:], d, tt.want)
+			}
+		})
+	}
+}
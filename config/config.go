@@ -1,123 +1,251 @@
 package config
 
 import (
-	"bruce/loader"
 	"bruce/operators"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v3"
 	"os"
+	"time"
 )
 
 // TemplateData will be marshalled from the provided config file that exists.
 type TemplateData struct {
-	Steps     []Steps           `yaml:"steps"`
-	Variables map[string]string `yaml:"variables"`
-	BackupDir string
+	Imports      StringList        `yaml:"imports"`
+	Steps        StepList          `yaml:"steps"`
+	Handlers     StepList          `yaml:"handlers"`
+	Variables    map[string]string `yaml:"variables"`
+	Rollback     bool              `yaml:"rollback"`
+	BackupDir    string            `yaml:"backupDir"`
+	BackupKeep   int               `yaml:"backupKeep"`
+	BackupMaxAge time.Duration     `yaml:"backupMaxAge"`
+	Workers      int               `yaml:"workers"`
+	Source       string            `yaml:"-"`
+	Hash         string            `yaml:"-"`
 }
 
-// Steps include multiple action operators to be executed per step
+// Steps include multiple action operators to be executed per step, the error policy fields apply to any operator.
 type Steps struct {
-	Name   string             `yaml:"name"`
-	Action operators.Operator `yaml:"action"`
+	ID           string             `yaml:"id"`
+	Name         string             `yaml:"name"`
+	Type         string             `yaml:"-"`
+	Action       operators.Operator `yaml:"action"`
+	IgnoreErrors bool               `yaml:"ignoreErrors"`
+	Retries      int                `yaml:"retries"`
+	RetryDelay   time.Duration      `yaml:"retryDelay"`
+	Timeout      time.Duration      `yaml:"timeout"`
+	Env          map[string]string  `yaml:"env"`
+	DependsOn    []string           `yaml:"dependsOn"`
+	Tags         []string           `yaml:"tags"`
+	Notify       StringList         `yaml:"notify"`
+	Include      string             `yaml:"-"`
+	Role         string             `yaml:"-"`
+	With         map[string]string  `yaml:"-"`
+	Defaults     map[string]string  `yaml:"-"`
+	Phase        int                `yaml:"-"`
 }
 
-// TODO: Add UnmarshallJSON
+// StepList is the ordered list of steps within a manifest.
+type StepList []Steps
 
-// UnmarshalYAML Implements the Unmarshaler interface of the yaml pkg.
-func (e *Steps) UnmarshalYAML(nd *yaml.Node) error {
-	// TODO: Fix this is the near future. (maybe plugin based?)
+// commonStepKeys are the keys handled by the step itself rather than the operator.
+var commonStepKeys = []string{"id", "name", "ignoreErrors", "retries", "retryDelay", "timeout", "env", "dependsOn", "tags", "notify"}
 
-	crn := &operators.Cron{}
-	if err := nd.Decode(crn); err == nil && len(crn.Schedule) > 0 {
-		log.Debug().Msg("matching cron operator")
-		e.Action = crn
-		return nil
+// UnmarshalYAML decodes each step in order so errors can refer to the step index.
+func (sl *StepList) UnmarshalYAML(nd *yaml.Node) error {
+	if nd.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: steps must be a list", nd.Line)
 	}
-
-	co := &operators.Command{}
-	if err := nd.Decode(co); err == nil && len(co.Cmd) > 0 {
-		log.Debug().Msg("matching command operator")
-		e.Action = co
-		return nil
+	steps := make(StepList, len(nd.Content))
+	for idx, snd := range nd.Content {
+		if err := snd.Decode(&steps[idx]); err != nil {
+			return fmt.Errorf("step [%d]: %w", idx+1, err)
+		}
 	}
+	*sl = steps
+	return nil
+}
 
-	tb := &operators.Tarball{}
-	if err := nd.Decode(tb); err == nil && len(tb.Src) > 0 {
-		log.Debug().Msg("matching tarball operator")
-		e.Action = tb
-		return nil
+// UnmarshalYAML Implements the Unmarshaler interface of the yaml pkg.
+func (e *Steps) UnmarshalYAML(nd *yaml.Node) error {
+	if hasKey(nd, "include") || hasKey(nd, "role") {
+		return e.decodeInclude(nd)
 	}
+	key, op, err := operators.Decode(nd, commonStepKeys...)
+	if err != nil {
+		return err
+	}
+	// the common fields may also be operator fields (eg: name for tarball) so they are read separately.
+	s := struct {
+		ID           string            `yaml:"id"`
+		Name         string            `yaml:"name"`
+		IgnoreErrors bool              `yaml:"ignoreErrors"`
+		Retries      int               `yaml:"retries"`
+		RetryDelay   time.Duration     `yaml:"retryDelay"`
+		Timeout      time.Duration     `yaml:"timeout"`
+		Env          map[string]string `yaml:"env"`
+		DependsOn    []string          `yaml:"dependsOn"`
+		Tags         []string          `yaml:"tags"`
+		Notify       StringList        `yaml:"notify"`
+	}{}
+	if err := nd.Decode(&s); err != nil {
+		return err
+	}
+	if s.Retries < 0 {
+		return fmt.Errorf("line %d: retries cannot be negative", nd.Line)
+	}
+	log.Debug().Msgf("matching %s operator", key)
+	e.ID = s.ID
+	e.Name = s.Name
+	e.IgnoreErrors = s.IgnoreErrors
+	e.Retries = s.Retries
+	e.RetryDelay = s.RetryDelay
+	e.Timeout = s.Timeout
+	e.Env = s.Env
+	e.DependsOn = s.DependsOn
+	e.Tags = s.Tags
+	e.Notify = s.Notify
+	e.Type = key
+	e.Action = op
+	return nil
+}
 
-	cp := &operators.Copy{}
-	if err := nd.Decode(cp); err == nil && len(cp.Src) > 0 {
-		log.Debug().Msg("matching copy operator")
-		e.Action = cp
-		return nil
+// decodeInclude reads an include or role step, it is replaced by the steps of the included manifest or role when the
+// manifest is loaded. The tags of the step are added to every included step, role steps may also set the role
+// parameters with: {key: value} and an id prefixing the step ids and handlers of the use (see manifestLoader.scope).
+func (e *Steps) decodeInclude(nd *yaml.Node) error {
+	s := struct {
+		ID      string            `yaml:"id"`
+		Include string            `yaml:"include"`
+		Role    string            `yaml:"role"`
+		With    map[string]string `yaml:"with"`
+		Tags    []string          `yaml:"tags"`
+	}{}
+	if err := nd.Decode(&s); err != nil {
+		return err
+	}
+	key, loc, allowed := "include", s.Include, "tags"
+	if hasKey(nd, "role") {
+		key, loc, allowed = "role", s.Role, "id, with and tags"
+	}
+	for i := 0; i+1 < len(nd.Content); i += 2 {
+		k := nd.Content[i].Value
+		if k != key && k != "tags" && ((k != "with" && k != "id") || key != "role") {
+			return fmt.Errorf("line %d: %s steps only accept %s, unknown key: %s", nd.Content[i].Line, key, allowed, k)
+		}
+	}
+	if len(loc) == 0 {
+		return fmt.Errorf("line %d: %s requires a location", nd.Line, key)
 	}
+	e.ID = s.ID
+	e.Include = s.Include
+	e.Role = s.Role
+	e.With = s.With
+	e.Tags = s.Tags
+	e.Type = key
+	return nil
+}
 
-	to := &operators.Template{}
-	if err := nd.Decode(to); err == nil && len(to.Template) > 0 {
-		log.Debug().Msg("matching template operator")
-		e.Action = to
-		return nil
+// hasKey returns true if the mapping node contains key.
+func hasKey(nd *yaml.Node, key string) bool {
+	if nd.Kind != yaml.MappingNode {
+		return false
 	}
+	for i := 0; i+1 < len(nd.Content); i += 2 {
+		if nd.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
 
-	gt := &operators.Git{}
-	if err := nd.Decode(gt); err == nil && len(gt.Repo) > 0 {
-		log.Debug().Msg("matching git operator")
-		e.Action = gt
+// StringList is a list of strings that may also be written as a single string.
+type StringList []string
+
+// UnmarshalYAML accepts a scalar as a list with a single value.
+func (sl *StringList) UnmarshalYAML(nd *yaml.Node) error {
+	if nd.Kind == yaml.ScalarNode {
+		*sl = StringList{nd.Value}
 		return nil
 	}
+	var l []string
+	if err := nd.Decode(&l); err != nil {
+		return err
+	}
+	*sl = l
+	return nil
+}
 
-	rc := &operators.RecursiveCopy{}
-	if err := nd.Decode(rc); err == nil && len(rc.Src) > 0 {
-		log.Debug().Msg("matching recursive copy operator")
-		e.Action = rc
-		return nil
+// Validate checks the relations between the steps and handlers of the manifest.
+func (t *TemplateData) Validate() error {
+	if t.Workers < 0 {
+		return fmt.Errorf("workers cannot be negative")
 	}
+	if _, err := t.Steps.Dependencies(); err != nil {
+		return err
+	}
+	return t.validateHandlers()
+}
 
-	lp := &operators.Loop{}
-	if err := nd.Decode(lp); err == nil && len(lp.LoopScript) > 0 {
-		log.Debug().Msg("matching loop operator")
-		e.Action = lp
-		return nil
+// validateHandlers ensures every notified handler exists, handlers may only notify the handlers defined after them
+// as handlers run once in the order they are defined.
+func (t *TemplateData) validateHandlers() error {
+	handlers := make(map[string]int)
+	for idx, h := range t.Handlers {
+		if len(h.Include) > 0 || len(h.Role) > 0 {
+			return fmt.Errorf("handler [%d]: %s is only supported in steps", idx+1, h.Type)
+		}
+		if len(h.Name) == 0 {
+			return fmt.Errorf("handler [%d]: a name is required to notify it", idx+1)
+		}
+		if prev, ok := handlers[h.Name]; ok {
+			return fmt.Errorf("handler [%d]: name %q is already used by handler [%d]", idx+1, h.Name, prev+1)
+		}
+		handlers[h.Name] = idx
 	}
-	re := &operators.RemoteExec{}
-	if err := nd.Decode(re); err == nil && len(re.ExecCmd) > 0 {
-		log.Debug().Msg("matching remote exec operator")
-		e.Action = re
-		return nil
+	for idx, s := range t.Steps {
+		for _, n := range s.Notify {
+			if _, ok := handlers[n]; !ok {
+				return fmt.Errorf("step [%d]: notify unknown handler %q", idx+1, n)
+			}
+		}
 	}
-	ap := &operators.API{}
-	if err := nd.Decode(ap); err == nil && len(ap.Endpoint) > 0 {
-		log.Debug().Msg("matching api operator")
-		e.Action = ap
-		return nil
+	for idx, h := range t.Handlers {
+		for _, n := range h.Notify {
+			d, ok := handlers[n]
+			if !ok {
+				return fmt.Errorf("handler [%d]: notify unknown handler %q", idx+1, n)
+			}
+			if d <= idx {
+				return fmt.Errorf("handler [%d]: can only notify handlers defined after it, %q is handler [%d]", idx+1, n, d+1)
+			}
+		}
 	}
-
-	log.Debug().Msg("no matching operator found, using null operator")
-	e.Action = &operators.NullOperator{}
 	return nil
 }
 
-// LoadConfig attempts to load the user provided manifest.
+// LoadConfig attempts to load the user provided manifest along with the manifests it imports and includes. Each
+// document of a multi-document manifest runs as a phase after the steps of the previous document completed.
 func LoadConfig(fileName string) (*TemplateData, error) {
-	d, _, err := loader.ReadRemoteFile(fileName)
-	if err != nil {
-		log.Error().Err(err).Msg("cannot proceed without a config file and specified config cannot be read.")
-		os.Exit(1)
-	}
-	log.Debug().Bytes("rawConfig", d)
-	c := &TemplateData{}
-
 	if os.Getenv("BRUCE_DEBUG") == "true" {
 		log.Debug().Msg("debug mode enabled")
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
-	err = yaml.Unmarshal(d, c)
+	l := &manifestLoader{hash: sha256.New()}
+	docs, err := l.load(fileName)
 	if err != nil {
-		log.Fatal().Err(err).Msg("could not parse config file")
+		log.Error().Err(err).Msg("could not load config file")
+		return nil, err
+	}
+	c := phases(docs)
+	if err := c.Validate(); err != nil {
+		log.Error().Err(err).Msgf("invalid manifest: %s", fileName)
+		return nil, fmt.Errorf("%s: %w", fileName, err)
 	}
+	c.Source = fileName
+	c.Hash = hex.EncodeToString(l.hash.Sum(nil))
 	return c, nil
 }
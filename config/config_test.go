@@ -0,0 +1,89 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateHandlers(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  string
+	}{
+		{
+			name: "notify single handler",
+			manifest: `
+steps:
+  - cmd: echo conf
+    notify: reload
+handlers:
+  - name: reload
+    cmd: echo reload
+    notify: [cleanup]
+  - name: cleanup
+    cmd: echo cleanup
+`,
+		},
+		{
+			name: "unknown handler",
+			manifest: `
+steps:
+  - cmd: echo conf
+    notify: [reload]
+`,
+			wantErr: `step [1]: notify unknown handler "reload"`,
+		},
+		{
+			name: "handler without name",
+			manifest: `
+handlers:
+  - cmd: echo reload
+`,
+			wantErr: "handler [1]: a name is required",
+		},
+		{
+			name: "duplicate handler",
+			manifest: `
+handlers:
+  - name: reload
+    cmd: echo reload
+  - name: reload
+    cmd: echo again
+`,
+			wantErr: `handler [2]: name "reload" is already used`,
+		},
+		{
+			name: "handler notifies earlier handler",
+			manifest: `
+handlers:
+  - name: reload
+    cmd: echo reload
+  - name: restart
+    cmd: echo restart
+    notify: reload
+`,
+			wantErr: "can only notify handlers defined after it",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td := &TemplateData{}
+			if err := yaml.Unmarshal([]byte(tt.manifest), td); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			err := td.Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dependencies returns the indices of the steps each step waits for. A step without dependsOn waits for the step
+// before it so manifests keep running in order unless they declare their dependencies, a step with an empty
+// dependsOn list does not wait for any other step. Every step of a phase also waits for the steps of the phase
+// before it. An error is returned for duplicate or unknown ids and cycles.
+func (sl StepList) Dependencies() ([][]int, error) {
+	ids := make(map[string]int)
+	for idx, s := range sl {
+		if len(s.ID) == 0 {
+			continue
+		}
+		if prev, ok := ids[s.ID]; ok {
+			return nil, fmt.Errorf("step [%d]: id %q is already used by step [%d]", idx+1, s.ID, prev+1)
+		}
+		ids[s.ID] = idx
+	}
+	deps := make([][]int, len(sl))
+	for idx, s := range sl {
+		if s.DependsOn == nil {
+			if idx > 0 {
+				deps[idx] = []int{idx - 1}
+			}
+			continue
+		}
+		deps[idx] = []int{}
+		for _, id := range s.DependsOn {
+			d, ok := ids[id]
+			if !ok {
+				return nil, fmt.Errorf("step [%d]: dependsOn unknown step id %q", idx+1, id)
+			}
+			if d == idx {
+				return nil, fmt.Errorf("step [%d]: cannot depend on itself", idx+1)
+			}
+			deps[idx] = append(deps[idx], d)
+		}
+	}
+	// the steps of a phase (a document of a multi-document manifest) also wait for every step of the phase before it
+	var prev, cur []int
+	for idx, s := range sl {
+		if idx > 0 && s.Phase != sl[idx-1].Phase {
+			prev, cur = cur, nil
+		}
+		cur = append(cur, idx)
+		for _, p := range prev {
+			if !containsIndex(deps[idx], p) {
+				deps[idx] = append(deps[idx], p)
+			}
+		}
+	}
+	if cycle := findCycle(deps); cycle != nil {
+		names := make([]string, len(cycle))
+		for i, idx := range cycle {
+			names[i] = sl.label(idx)
+		}
+		return nil, fmt.Errorf("dependency cycle between steps: %s", strings.Join(names, " -> "))
+	}
+	return deps, nil
+}
+
+func containsIndex(l []int, idx int) bool {
+	for _, i := range l {
+		if i == idx {
+			return true
+		}
+	}
+	return false
+}
+
+// label describes a step in errors by its position and id.
+func (sl StepList) label(idx int) string {
+	if len(sl[idx].ID) > 0 {
+		return fmt.Sprintf("[%d] %s", idx+1, sl[idx].ID)
+	}
+	return fmt.Sprintf("[%d]", idx+1)
+}
+
+// findCycle returns the steps of a dependency cycle, starting and ending with the same step, or nil if there is none.
+func findCycle(deps [][]int) []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(deps))
+	var path []int
+	var visit func(idx int) []int
+	visit = func(idx int) []int {
+		state[idx] = visiting
+		path = append(path, idx)
+		for _, d := range deps[idx] {
+			switch state[d] {
+			case visiting:
+				for i, p := range path {
+					if p == d {
+						return append(append([]int{}, path[i:]...), d)
+					}
+				}
+			case unvisited:
+				if c := visit(d); c != nil {
+					return c
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[idx] = visited
+		return nil
+	}
+	for idx := range deps {
+		if state[idx] == unvisited {
+			if c := visit(idx); c != nil {
+				return c
+			}
+		}
+	}
+	return nil
+}
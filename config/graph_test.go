@@ -0,0 +1,79 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStepListDependencies(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   StepList
+		want    [][]int
+		wantErr string
+	}{
+		{
+			name:  "sequential by default",
+			steps: StepList{{}, {}, {}},
+			want:  [][]int{nil, {0}, {1}},
+		},
+		{
+			name:  "independent steps",
+			steps: StepList{{ID: "a"}, {ID: "b", DependsOn: []string{}}, {DependsOn: []string{"a", "b"}}},
+			want:  [][]int{nil, {}, {0, 1}},
+		},
+		{
+			name:  "forward reference",
+			steps: StepList{{DependsOn: []string{"later"}}, {ID: "later", DependsOn: []string{}}},
+			want:  [][]int{{1}, {}},
+		},
+		{
+			name:  "phases",
+			steps: StepList{{ID: "a", DependsOn: []string{}}, {DependsOn: []string{}}, {Phase: 1, DependsOn: []string{}}, {Phase: 1}},
+			want:  [][]int{{}, {}, {0, 1}, {2, 0, 1}},
+		},
+		{
+			name:    "dependency on a later phase",
+			steps:   StepList{{ID: "a", DependsOn: []string{"b"}}, {ID: "b", Phase: 1}},
+			wantErr: "dependency cycle between steps",
+		},
+		{
+			name:    "duplicate id",
+			steps:   StepList{{ID: "a"}, {ID: "a"}},
+			wantErr: `step [2]: id "a" is already used by step [1]`,
+		},
+		{
+			name:    "unknown id",
+			steps:   StepList{{DependsOn: []string{"nope"}}},
+			wantErr: `step [1]: dependsOn unknown step id "nope"`,
+		},
+		{
+			name:    "self dependency",
+			steps:   StepList{{ID: "a", DependsOn: []string{"a"}}},
+			wantErr: "cannot depend on itself",
+		},
+		{
+			name:    "cycle",
+			steps:   StepList{{ID: "a", DependsOn: []string{"c"}}, {ID: "b"}, {ID: "c"}},
+			wantErr: "dependency cycle between steps: [1] a -> [3] c -> [2] b -> [1] a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.steps.Dependencies()
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Dependencies() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Dependencies() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dependencies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
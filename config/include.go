@@ -0,0 +1,332 @@
+package config
+
+import (
+	"bruce/format"
+	"bruce/loader"
+	"bruce/operators"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"hash"
+	"maps"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// manifestLoader reads a manifest along with the manifests it imports and includes, the content of every manifest
+// read is added to the hash so a change to any of them changes the manifest hash.
+type manifestLoader struct {
+	stack  []string
+	hash   hash.Hash
+	loaded map[string]bool
+	roles  map[string][]roleUse
+}
+
+// roleUse is a use of a role within the manifest, names maps the handler names of the role to the handlers notified
+// by the steps of this use.
+type roleUse struct {
+	with  map[string]string
+	names map[string]string
+}
+
+// load reads the documents of the manifest at location with its imports and include steps expanded, relative
+// locations are resolved against the manifest that refers to them. A manifest may be loaded several times (eg: an
+// include used twice) but its handlers are only kept the first time, so each handler is defined and run once.
+func (l *manifestLoader) load(location string) ([]*TemplateData, error) {
+	if !strings.Contains(location, "://") {
+		location = filepath.Clean(location)
+	}
+	for _, s := range l.stack {
+		if s == location {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(l.stack, " -> "), location)
+		}
+	}
+	l.stack = append(l.stack, location)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+
+	d, _, err := loader.ReadRemoteFile(location)
+	if err != nil {
+		return nil, fmt.Errorf("could not read manifest %s: %w", location, err)
+	}
+	log.Debug().Bytes("rawConfig", d).Msgf("read manifest: %s", location)
+	l.hash.Write(d)
+	docs, err := parseDocuments(location, d)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", location, err)
+	}
+	if l.loaded == nil {
+		l.loaded = make(map[string]bool)
+	}
+	repeated := l.loaded[location]
+	l.loaded[location] = true
+	for _, doc := range docs {
+		if repeated {
+			doc.Handlers = nil
+		}
+		if err := l.expand(doc, location); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// expand replaces the imports and include steps of doc with the steps of the manifests they refer to. Imported
+// steps run before the steps of doc, included steps run in place of the include step.
+func (l *manifestLoader) expand(doc *TemplateData, location string) error {
+	var steps, handlers StepList
+	for _, imp := range doc.Imports {
+		m, err := l.manifest(resolveLocation(location, imp))
+		if err != nil {
+			return err
+		}
+		steps = append(steps, m.Steps...)
+		handlers = append(handlers, m.Handlers...)
+		doc.inherit(m)
+	}
+	for _, s := range doc.Steps {
+		var m *TemplateData
+		var err error
+		switch {
+		case len(s.Role) > 0:
+			m, err = l.role(resolveLocation(location, s.Role), s.ID, s.With)
+		case len(s.Include) > 0:
+			m, err = l.manifest(resolveLocation(location, s.Include))
+			if err == nil {
+				doc.inherit(m)
+			}
+		default:
+			steps = append(steps, s)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, is := range m.Steps {
+			is.Tags = append(append([]string{}, is.Tags...), s.Tags...)
+			steps = append(steps, is)
+		}
+		handlers = append(handlers, m.Handlers...)
+	}
+	doc.Steps = steps
+	doc.Handlers = append(handlers, doc.Handlers...)
+	doc.Imports = nil
+	return nil
+}
+
+// manifest loads an imported or included manifest as a single manifest, its documents run one after the other.
+func (l *manifestLoader) manifest(location string) (*TemplateData, error) {
+	docs, err := l.load(location)
+	if err != nil {
+		return nil, err
+	}
+	m := &TemplateData{}
+	for _, doc := range docs {
+		m.Steps = append(m.Steps, doc.Steps...)
+		m.Handlers = append(m.Handlers, doc.Handlers...)
+		m.inherit(doc)
+	}
+	return m, nil
+}
+
+// roleManifest is the manifest of a role within the role directory (or remote prefix).
+const roleManifest = "manifest.yml"
+
+// role loads the manifest of the role at root for a single use of the role. The variables of the role manifest are
+// defaults only used when the run does not set them, the parameters given with the role take precedence over the
+// run variables. Both only apply to the steps and handlers of the role, which resolve relative template sources
+// against the role root. A role may be used more than once, see scope for the step ids and handlers of later uses.
+func (l *manifestLoader) role(root, id string, with map[string]string) (*TemplateData, error) {
+	// relocated sources of nested roles must not be resolved again by the roles using them
+	if abs, err := filepath.Abs(root); err == nil && !strings.Contains(root, "://") {
+		root = abs
+	}
+	location := joinLocation(root, roleManifest)
+	// every use of a role defines its handlers, scope decides whether they are kept
+	delete(l.loaded, location)
+	m, err := l.manifest(location)
+	if err != nil {
+		return nil, err
+	}
+	resolve := func(src string) string {
+		if len(src) == 0 || strings.HasPrefix(src, "$") {
+			return src
+		}
+		return resolveLocation(location, src)
+	}
+	for _, sl := range []StepList{m.Steps, m.Handlers} {
+		for idx := range sl {
+			sl[idx].Defaults = overlay(m.Variables, sl[idx].Defaults)
+			sl[idx].With = overlay(with, sl[idx].With)
+			if r, ok := sl[idx].Action.(operators.Relocator); ok {
+				r.Relocate(resolve)
+			}
+		}
+	}
+	l.scope(root, id, with, m)
+	return m, nil
+}
+
+// scope keeps the step ids and handlers of the uses of a role apart. The first use keeps the ids and handler names
+// of the role, later uses prefix them with the id of the role step (or <role>#<use> without one) as <prefix>/<name>.
+// A later use with the same parameters as an earlier one shares the handlers of that use so they still run once.
+func (l *manifestLoader) scope(root, id string, with map[string]string, m *TemplateData) {
+	if l.roles == nil {
+		l.roles = make(map[string][]roleUse)
+	}
+	uses := l.roles[root]
+	use := roleUse{with: with, names: make(map[string]string)}
+	prefix := id
+	if len(prefix) == 0 {
+		prefix = fmt.Sprintf("%s#%d", path.Base(filepath.ToSlash(root)), len(uses)+1)
+	}
+	scoped := func(name string) string {
+		if len(uses) == 0 {
+			return name
+		}
+		return prefix + "/" + name
+	}
+	for _, u := range uses {
+		if maps.Equal(u.with, with) {
+			use.names = u.names
+			m.Handlers = nil
+			break
+		}
+	}
+	for idx := range m.Handlers {
+		use.names[m.Handlers[idx].Name] = scoped(m.Handlers[idx].Name)
+		m.Handlers[idx].Name = use.names[m.Handlers[idx].Name]
+	}
+	l.roles[root] = append(uses, use)
+	ids := make(map[string]string)
+	for idx := range m.Steps {
+		if len(m.Steps[idx].ID) > 0 {
+			ids[m.Steps[idx].ID] = scoped(m.Steps[idx].ID)
+			m.Steps[idx].ID = ids[m.Steps[idx].ID]
+		}
+	}
+	for _, sl := range []StepList{m.Steps, m.Handlers} {
+		for idx := range sl {
+			sl[idx].DependsOn = rename(sl[idx].DependsOn, ids)
+			sl[idx].Notify = rename(sl[idx].Notify, use.names)
+		}
+	}
+}
+
+// rename returns a copy of names with the names found in to replaced, a nil list stays nil.
+func rename(names []string, to map[string]string) []string {
+	if names == nil {
+		return nil
+	}
+	r := make([]string, len(names))
+	for i, n := range names {
+		if t, ok := to[n]; ok {
+			n = t
+		}
+		r[i] = n
+	}
+	return r
+}
+
+// overlay returns a copy of base with the values of top taking precedence.
+func overlay(base, top map[string]string) map[string]string {
+	if len(base) == 0 && len(top) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(base)+len(top))
+	for k, v := range base {
+		m[k] = v
+	}
+	for k, v := range top {
+		m[k] = v
+	}
+	return m
+}
+
+// phases combines the documents of a manifest into one, the steps of each document form a phase that only starts
+// once the steps of the previous phase completed. The settings of the first document take precedence.
+func phases(docs []*TemplateData) *TemplateData {
+	c := &TemplateData{}
+	for idx, doc := range docs {
+		for _, s := range doc.Steps {
+			s.Phase = idx
+			c.Steps = append(c.Steps, s)
+		}
+		c.Handlers = append(c.Handlers, doc.Handlers...)
+		c.inherit(doc)
+	}
+	return c
+}
+
+// inherit copies the variables and settings of o that t does not set itself.
+func (t *TemplateData) inherit(o *TemplateData) {
+	for k, v := range o.Variables {
+		if _, ok := t.Variables[k]; ok {
+			continue
+		}
+		if t.Variables == nil {
+			t.Variables = make(map[string]string)
+		}
+		t.Variables[k] = v
+	}
+	t.Rollback = t.Rollback || o.Rollback
+	if len(t.BackupDir) == 0 {
+		t.BackupDir = o.BackupDir
+	}
+	if t.BackupKeep == 0 {
+		t.BackupKeep = o.BackupKeep
+	}
+	if t.BackupMaxAge == 0 {
+		t.BackupMaxAge = o.BackupMaxAge
+	}
+	if t.Workers == 0 {
+		t.Workers = o.Workers
+	}
+}
+
+// parseDocuments decodes every document of a manifest written as YAML (which may hold several documents), JSON or
+// TOML.
+func parseDocuments(location string, d []byte) ([]*TemplateData, error) {
+	nds, err := format.Documents(location, d)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]*TemplateData, len(nds))
+	for idx, nd := range nds {
+		docs[idx] = &TemplateData{}
+		if err := nd.Decode(docs[idx]); err != nil {
+			if idx > 0 {
+				return nil, fmt.Errorf("document %d: %w", idx+1, err)
+			}
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// joinLocation returns the location of name within the directory (or url prefix) root.
+func joinLocation(root, name string) string {
+	if strings.Contains(root, "://") {
+		return strings.TrimSuffix(root, "/") + "/" + name
+	}
+	return filepath.Join(root, name)
+}
+
+// resolveLocation returns the location of ref, relative locations are resolved against the directory (or url path)
+// of the parent manifest.
+func resolveLocation(parent, ref string) string {
+	if strings.Contains(ref, "://") || filepath.IsAbs(ref) || path.IsAbs(ref) {
+		return ref
+	}
+	if strings.Contains(parent, "://") {
+		u, err := url.Parse(parent)
+		if err != nil {
+			return ref
+		}
+		u.Path = path.Join(path.Dir(u.Path), ref)
+		u.RawPath = ""
+		u.RawQuery = ""
+		return u.String()
+	}
+	return filepath.Join(filepath.Dir(parent), ref)
+}
@@ -0,0 +1,343 @@
+package config
+
+import (
+	"bruce/operators"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeManifests(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		fn := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadConfigIncludes(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"main.yml": `
+imports: [common/base.yml]
+variables:
+  PORT: "80"
+steps:
+  - cmd: echo main
+  - include: nginx/nginx.yml
+    tags: [nginx]
+---
+steps:
+  - cmd: echo second phase
+`,
+		"common/base.yml": `
+variables:
+  PORT: "8080"
+  USER: app
+steps:
+  - cmd: echo base
+`,
+		"nginx/nginx.yml": `
+steps:
+  - cmd: echo nginx
+    notify: reload nginx
+handlers:
+  - name: reload nginx
+    cmd: echo reload
+`,
+	})
+	c, err := LoadConfig(filepath.Join(dir, "main.yml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if len(c.Steps) != 4 || len(c.Handlers) != 1 {
+		t.Fatalf("LoadConfig() = %d steps, %d handlers, want 4 steps and 1 handler", len(c.Steps), len(c.Handlers))
+	}
+	if c.Steps[2].Tags[0] != "nginx" || c.Steps[3].Phase != 1 {
+		t.Errorf("LoadConfig() steps = %+v, want the include tags and a second phase", c.Steps)
+	}
+	if c.Variables["PORT"] != "80" || c.Variables["USER"] != "app" {
+		t.Errorf("LoadConfig() variables = %v, want the manifest to override its imports", c.Variables)
+	}
+	if len(c.Hash) == 0 {
+		t.Errorf("LoadConfig() did not hash the manifests")
+	}
+}
+
+func TestLoadConfigRoles(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"main.yml": `
+steps:
+  - role: roles/web
+    with: {port: 8080}
+    tags: [web]
+  - cmd: echo done
+`,
+		"roles/web/manifest.yml": `
+variables:
+  port: "80"
+steps:
+  - template: /etc/web.conf
+    source: templates/web.conf
+  - template: /etc/other.conf
+    source: ${TEMPLATES}/other.conf
+handlers:
+  - name: reload web
+    cmd: echo reload
+`,
+	})
+	c, err := LoadConfig(filepath.Join(dir, "main.yml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if len(c.Steps) != 3 || len(c.Handlers) != 1 {
+		t.Fatalf("LoadConfig() = %d steps, %d handlers, want 3 steps and 1 handler", len(c.Steps), len(c.Handlers))
+	}
+	role := c.Steps[0]
+	if role.With["port"] != "8080" || role.Defaults["port"] != "80" || role.Tags[0] != "web" {
+		t.Errorf("LoadConfig() role step = %+v, want the role parameters, defaults and tags", role)
+	}
+	if c.Variables["port"] != "" || c.Steps[2].With != nil || c.Handlers[0].With["port"] != "8080" {
+		t.Errorf("LoadConfig() role variables should only apply to the steps and handlers of the role")
+	}
+	if src := role.Action.(*operators.Template).RemoteLoc; src != filepath.Join(dir, "roles/web/templates/web.conf") {
+		t.Errorf("LoadConfig() template source = %s, want it relative to the role", src)
+	}
+	if src := c.Steps[1].Action.(*operators.Template).RemoteLoc; src != "${TEMPLATES}/other.conf" {
+		t.Errorf("LoadConfig() template source = %s, variable sources should be left as is", src)
+	}
+}
+
+func TestLoadConfigRoleReuse(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"main.yml": `
+steps:
+  - role: roles/site
+    with: {site: a}
+  - role: roles/site
+    with: {site: b}
+  - role: roles/site
+    id: again
+    with: {site: a}
+  - cmd: echo done
+    dependsOn: [render, site#2/render]
+`,
+		"roles/site/manifest.yml": `
+steps:
+  - id: render
+    cmd: echo ${site}
+  - id: reload
+    cmd: echo ${site}
+    dependsOn: [render]
+    notify: reload web
+handlers:
+  - name: reload web
+    cmd: echo reload ${site}
+`,
+	})
+	c, err := LoadConfig(filepath.Join(dir, "main.yml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if len(c.Steps) != 7 || len(c.Handlers) != 2 {
+		t.Fatalf("LoadConfig() = %d steps, %d handlers, want 7 steps and 2 handlers", len(c.Steps), len(c.Handlers))
+	}
+	tests := []struct {
+		name       string
+		step       Steps
+		wantID     string
+		wantDepend string
+		wantNotify string
+		wantSite   string
+	}{
+		{name: "first use", step: c.Steps[1], wantID: "reload", wantDepend: "render", wantNotify: "reload web", wantSite: "a"},
+		{name: "use with other parameters", step: c.Steps[3], wantID: "site#2/reload", wantDepend: "site#2/render", wantNotify: "site#2/reload web", wantSite: "b"},
+		{name: "use with an id and the first parameters", step: c.Steps[5], wantID: "again/reload", wantDepend: "again/render", wantNotify: "reload web", wantSite: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.step
+			if s.ID != tt.wantID || len(s.DependsOn) != 1 || s.DependsOn[0] != tt.wantDepend || s.With["site"] != tt.wantSite {
+				t.Errorf("LoadConfig() step = %s depending on %v with %v, want %s depending on %s with site %s", s.ID, s.DependsOn, s.With, tt.wantID, tt.wantDepend, tt.wantSite)
+			}
+			if len(s.Notify) != 1 || s.Notify[0] != tt.wantNotify {
+				t.Errorf("LoadConfig() notify = %v, want %s", s.Notify, tt.wantNotify)
+			}
+		})
+	}
+	if h := c.Handlers; h[0].Name != "reload web" || h[0].With["site"] != "a" || h[1].Name != "site#2/reload web" || h[1].With["site"] != "b" {
+		t.Errorf("LoadConfig() handlers = [%s %v, %s %v], want one per parameters", h[0].Name, h[0].With, h[1].Name, h[1].With)
+	}
+}
+
+func TestLoadConfigRepeatedIncludes(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"main.yml": `
+imports: [nginx.yml]
+steps:
+  - include: nginx.yml
+  - include: app.yml
+  - include: nginx.yml
+`,
+		"app.yml": `
+steps:
+  - include: nginx.yml
+  - cmd: echo app
+    notify: restart app
+handlers:
+  - name: restart app
+    cmd: echo restart
+`,
+		"nginx.yml": `
+steps:
+  - cmd: echo nginx
+    notify: reload nginx
+handlers:
+  - name: reload nginx
+    cmd: echo reload
+`,
+	})
+	c, err := LoadConfig(filepath.Join(dir, "main.yml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if len(c.Steps) != 5 {
+		t.Errorf("LoadConfig() = %d steps, want the steps of every include", len(c.Steps))
+	}
+	var names []string
+	for _, h := range c.Handlers {
+		names = append(names, h.Name)
+	}
+	if strings.Join(names, ",") != "reload nginx,restart app" {
+		t.Errorf("LoadConfig() handlers = %v, want each handler once", names)
+	}
+}
+
+func TestLoadConfigIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"main.yml": "steps:\n  - include: a.yml\n",
+				"a.yml":    "imports: [main.yml]\n",
+			},
+			wantErr: "include cycle:",
+		},
+		{
+			name:    "missing",
+			files:   map[string]string{"main.yml": "steps:\n  - include: missing.yml\n"},
+			wantErr: "could not read manifest",
+		},
+		{
+			name:    "unknown key",
+			files:   map[string]string{"main.yml": "steps:\n  - include: a.yml\n    retries: 2\n"},
+			wantErr: "include steps only accept tags",
+		},
+		{
+			name:    "role without manifest",
+			files:   map[string]string{"main.yml": "steps:\n  - role: roles/missing\n"},
+			wantErr: "roles/missing/manifest.yml",
+		},
+		{
+			name:    "with on include",
+			files:   map[string]string{"main.yml": "steps:\n  - include: a.yml\n    with: {a: b}\n"},
+			wantErr: "include steps only accept tags",
+		},
+		{
+			name:    "handler include",
+			files:   map[string]string{"main.yml": "handlers:\n  - include: a.yml\n", "a.yml": "steps: []\n"},
+			wantErr: "include is only supported in steps",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeManifests(t, tt.files)
+			_, err := LoadConfig(filepath.Join(dir, "main.yml"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveLocation(t *testing.T) {
+	tests := []struct {
+		parent, ref, want string
+	}{
+		{parent: "/etc/bruce/main.yml", ref: "roles/web.yml", want: "/etc/bruce/roles/web.yml"},
+		{parent: "/etc/bruce/main.yml", ref: "/opt/other.yml", want: "/opt/other.yml"},
+		{parent: "https://example.com/m/main.yml?x=1", ref: "../common.yml", want: "https://example.com/common.yml"},
+		{parent: "s3://bucket/m/main.yml", ref: "web.yml", want: "s3://bucket/m/web.yml"},
+		{parent: "/etc/bruce/main.yml", ref: "s3://bucket/web.yml", want: "s3://bucket/web.yml"},
+	}
+	for _, tt := range tests {
+		if got := resolveLocation(tt.parent, tt.ref); got != tt.want {
+			t.Errorf("resolveLocation(%q, %q) = %q, want %q", tt.parent, tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"main.json": `{
+  "imports": ["base.toml"],
+  "variables": {"A": "json"},
+  "steps": [
+    {"cmd": "echo json", "retries": 2, "retryDelay": "2s", "tags": ["json"]}
+  ]
+}`,
+		"base.toml": `
+[variables]
+A = "toml"
+B = "toml"
+
+[[steps]]
+cmd = "echo toml"
+notify = "reload"
+
+[[handlers]]
+name = "reload"
+cmd = "echo reload"
+`,
+		"bad.json": "{\n  \"steps\": [\n    {\"cmd\": \"echo a\"},\n    {\"nope\": true}\n  ]\n}",
+		"bad.toml": "[[steps]]\ncmd = \"echo a\"\n\n[[steps]]\ncmd = \"echo b\"\nretries = -1\n",
+		"manifest": "{\"steps\": [{\"cmd\": \"echo sniffed\"}]}",
+	})
+	c, err := LoadConfig(filepath.Join(dir, "main.json"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if len(c.Steps) != 2 || len(c.Handlers) != 1 || c.Steps[1].Retries != 2 || c.Steps[1].Tags[0] != "json" {
+		t.Errorf("LoadConfig() = %+v, want the toml import followed by the json step", c.Steps)
+	}
+	if c.Variables["A"] != "json" || c.Variables["B"] != "toml" {
+		t.Errorf("LoadConfig() variables = %v", c.Variables)
+	}
+	if c, err := LoadConfig(filepath.Join(dir, "manifest")); err != nil || len(c.Steps) != 1 {
+		t.Errorf("LoadConfig() sniffed json = %v, %v", c, err)
+	}
+
+	tests := []struct {
+		file    string
+		wantErr string
+	}{
+		{file: "bad.json", wantErr: "step [2]: no operator matches keys: nope"},
+		{file: "bad.toml", wantErr: "step [2]: line 4: retries cannot be negative"},
+	}
+	for _, tt := range tests {
+		if _, err := LoadConfig(filepath.Join(dir, tt.file)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("LoadConfig(%s) error = %v, want %q", tt.file, err, tt.wantErr)
+		}
+	}
+}
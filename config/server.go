@@ -1,23 +1,36 @@
 package config
 
 import (
+	"bruce/logging"
 	"gopkg.in/yaml.v3"
 	"os"
 )
 
+// Execution is a manifest run by the server, either on a cadence or when triggered by an event.
+// The property file and variables are applied on top of the manifest variables for every run.
 type Execution struct {
-	Name    string `yaml:"name"`
-	Action  string `yaml:"action"`
-	Type    string `yaml:"type"`
-	Cadence int    `yaml:"cadence"`
-	Target  string `yaml:"target"`
+	Name         string            `yaml:"name"`
+	Action       string            `yaml:"action"`
+	Type         string            `yaml:"type"`
+	Cadence      int               `yaml:"cadence"`
+	Target       string            `yaml:"target"`
+	Report       string            `yaml:"report"`
+	PropertyFile string            `yaml:"property-file"`
+	Variables    map[string]string `yaml:"variables"`
+	Lock         string            `yaml:"lock"`
+	Workers      int               `yaml:"workers"`
+	Tags         []string          `yaml:"tags"`
+	SkipTags     []string          `yaml:"skip-tags"`
+	StartAtStep  string            `yaml:"start-at-step"`
+	StepNames    []string          `yaml:"step-name"`
 }
 
 type ServerConfig struct {
-	RunnerID      string      `yaml:"runner-id"`
-	Authorization string      `yaml:"authorization"`
-	Endpoint      string      `yaml:"endpoint"`
-	Execution     []Execution `yaml:"execution"`
+	RunnerID      string          `yaml:"runner-id"`
+	Authorization string          `yaml:"authorization"`
+	Endpoint      string          `yaml:"endpoint"`
+	Execution     []Execution     `yaml:"execution"`
+	Log           logging.Options `yaml:"log"`
 }
 
 func ReadServerConfig(l string, sc *ServerConfig) error {
@@ -2,6 +2,9 @@ package exe
 
 import (
 	"bruce/random"
+	"bruce/vars"
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -18,6 +21,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Execution struct {
@@ -25,6 +29,8 @@ type Execution struct {
 	fields      []string
 	useSudo     bool
 	outputStr   string
+	stdout      string
+	stderr      string
 	isError     bool
 	cmnd        string
 	args        []string
@@ -176,6 +182,12 @@ func SetOwnership(obType, opath, owner, group string, recursive bool) error {
 }
 
 func Run(c, dir string) *Execution {
+	return RunContext(context.Background(), c, dir)
+}
+
+// RunContext is Run where the process is killed once ctx is cancelled or times out, the environment of the process
+// is the variable scope of ctx.
+func RunContext(ctx context.Context, c, dir string) *Execution {
 	e := &Execution{}
 	e.input = c
 	e.fields = strings.Fields(c)
@@ -188,15 +200,27 @@ func Run(c, dir string) *Execution {
 		e.args = e.fields[1:]
 	}
 
-	cmd := exec.Command(e.cmnd, e.args...)
+	cmd := exec.CommandContext(ctx, e.cmnd, e.args...)
+	killProcessGroup(cmd)
+	// children that left the process group may hold the output pipes open so waiting on them is limited
+	cmd.WaitDelay = 5 * time.Second
+	cmd.Env = vars.FromContext(ctx).Environ()
 	if dir != "" {
 		cmd.Dir = dir
 	}
-	d, err := cmd.CombinedOutput()
+	var combined, outb, errb bytes.Buffer
+	cmd.Stdout = io.MultiWriter(&combined, &outb)
+	cmd.Stderr = io.MultiWriter(&combined, &errb)
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
 	if err != nil {
 		e.isError = true
 	}
-	e.outputStr = strings.TrimSuffix(strings.TrimLeft(strings.TrimRight(string(d), " "), " "), "\n")
+	e.outputStr = strings.TrimSuffix(strings.TrimLeft(strings.TrimRight(combined.String(), " "), " "), "\n")
+	e.stdout = outb.String()
+	e.stderr = errb.String()
 	if err != nil {
 		e.err = fmt.Errorf("%s", strings.TrimSuffix(strings.TrimLeft(strings.TrimRight(err.Error(), " "), " "), "\n"))
 	}
@@ -224,6 +248,16 @@ func (e *Execution) Get() string {
 	return e.outputStr
 }
 
+// Stdout returns the raw standard output of the command.
+func (e *Execution) Stdout() string {
+	return e.stdout
+}
+
+// Stderr returns the raw standard error output of the command.
+func (e *Execution) Stderr() string {
+	return e.stderr
+}
+
 // GetErrStr will return the currently populated error output string even if it's empty
 func (e *Execution) GetErrStr() string {
 	if e.err != nil {
@@ -0,0 +1,46 @@
+package exe
+
+import (
+	"os/user"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// LookupIds resolves an owner and group by name or numeric id, empty values return -1 to leave them unchanged.
+func LookupIds(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner != "" {
+		if id, err := strconv.Atoi(owner); err == nil {
+			uid = id
+		} else {
+			usr, err := user.Lookup(owner)
+			if err != nil {
+				log.Error().Msgf("cannot lookup user for %s", owner)
+				return -1, -1, err
+			}
+			uid, err = strconv.Atoi(usr.Uid)
+			if err != nil {
+				log.Error().Msgf("not a valid user id number to convert to int")
+				return -1, -1, err
+			}
+		}
+	}
+	if group != "" {
+		if id, err := strconv.Atoi(group); err == nil {
+			gid = id
+		} else {
+			grp, err := user.LookupGroup(group)
+			if err != nil {
+				log.Error().Msgf("cannot lookup group for %s", group)
+				return -1, -1, err
+			}
+			gid, err = strconv.Atoi(grp.Gid)
+			if err != nil {
+				log.Error().Msgf("not a valid group id number to convert to int")
+				return -1, -1, err
+			}
+		}
+	}
+	return uid, gid, nil
+}
@@ -0,0 +1,67 @@
+//go:build !windows
+
+package exe
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ApplyOwnership sets the uid, gid and mode of a path (or tree when recursive) only where they differ.
+// A uid / gid of -1 or a mode / dirMode of 0 leaves that attribute unchanged, dirMode applies to directories and
+// falls back to mode when not set. It returns the number of paths that were changed, with dryRun nothing is altered
+// and the number of paths that would change is returned.
+func ApplyOwnership(opath string, uid, gid int, mode, dirMode fs.FileMode, recursive, dryRun bool) (int, error) {
+	changed := 0
+	apply := func(p string, fi os.FileInfo) error {
+		altered := false
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			if (uid >= 0 && int(st.Uid) != uid) || (gid >= 0 && int(st.Gid) != gid) {
+				if dryRun {
+					log.Info().Msgf("would change ownership: %s", p)
+				} else if err := os.Lchown(p, uid, gid); err != nil {
+					return err
+				}
+				altered = true
+			}
+		}
+		want := mode
+		if fi.IsDir() && dirMode != 0 {
+			want = dirMode
+		}
+		if want != 0 && fi.Mode()&os.ModeSymlink == 0 && fi.Mode().Perm() != want.Perm() {
+			if dryRun {
+				log.Info().Msgf("would change mode: %s (%o => %o)", p, fi.Mode().Perm(), want.Perm())
+			} else if err := os.Chmod(p, want.Perm()); err != nil {
+				return err
+			}
+			altered = true
+		}
+		if altered {
+			log.Debug().Msgf("ownership updated: %s", p)
+			changed++
+		}
+		return nil
+	}
+	fi, err := os.Lstat(opath)
+	if err != nil {
+		return 0, err
+	}
+	if !recursive || !fi.IsDir() {
+		return changed, apply(opath, fi)
+	}
+	err = filepath.Walk(opath, func(p string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return apply(p, f)
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("could not recursively set ownership")
+	}
+	return changed, err
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package exe
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// ApplyOwnership only supports setting the file mode on windows, ownership changes return an error.
+func ApplyOwnership(opath string, uid, gid int, mode, dirMode fs.FileMode, recursive, dryRun bool) (int, error) {
+	if uid >= 0 || gid >= 0 {
+		return 0, fmt.Errorf("ownership changes are not supported on windows")
+	}
+	fi, err := os.Stat(opath)
+	if err != nil {
+		return 0, err
+	}
+	want := mode
+	if fi.IsDir() && dirMode != 0 {
+		want = dirMode
+	}
+	if want == 0 || fi.Mode().Perm() == want.Perm() {
+		return 0, nil
+	}
+	if dryRun {
+		return 1, nil
+	}
+	return 1, os.Chmod(opath, want)
+}
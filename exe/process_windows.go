@@ -0,0 +1,8 @@
+//go:build windows
+
+package exe
+
+import "os/exec"
+
+// killProcessGroup is a no-op on windows, only the started process is killed on cancellation.
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,108 @@
+// Package format reads manifests and property files written as YAML, JSON or TOML. Every format is converted to
+// yaml nodes so the documents are decoded (and report errors) the same way regardless of the format.
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the syntax of a manifest or property file.
+type Format string
+
+const (
+	YAML Format = "yaml"
+	JSON Format = "json"
+	TOML Format = "toml"
+)
+
+// tomlLine matches the first line of a TOML document: a table header or a key = value pair.
+var tomlLine = regexp.MustCompile(`^(\[\[?\s*[A-Za-z0-9_.\-"' ]+\s*\]\]?|[A-Za-z0-9_\-"']+\s*=)`)
+
+// Detect returns the format of the file at location from its extension, the content is sniffed when the extension
+// is not a known one.
+func Detect(location string, d []byte) Format {
+	if u, err := url.Parse(location); err == nil && strings.Contains(location, "://") {
+		location = u.Path
+	}
+	switch strings.ToLower(path.Ext(location)) {
+	case ".json":
+		return JSON
+	case ".toml":
+		return TOML
+	case ".yml", ".yaml":
+		return YAML
+	}
+	return sniff(d)
+}
+
+// sniff guesses the format from the first line that is not blank or a comment.
+func sniff(d []byte) Format {
+	s := bufio.NewScanner(bytes.NewReader(d))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "{") || strings.HasPrefix(line, "["):
+			// a TOML table header also starts with [ but never holds a JSON value
+			if tomlLine.MatchString(line) && !strings.HasPrefix(line, "[{") && !strings.HasPrefix(line, `["`) {
+				return TOML
+			}
+			return JSON
+		case tomlLine.MatchString(line):
+			return TOML
+		}
+		return YAML
+	}
+	return YAML
+}
+
+// Documents returns the documents of the file at location as yaml nodes, only YAML files may hold several documents.
+func Documents(location string, d []byte) ([]*yaml.Node, error) {
+	switch Detect(location, d) {
+	case JSON:
+		nd, err := jsonNode(d)
+		if err != nil {
+			return nil, err
+		}
+		return []*yaml.Node{nd}, nil
+	case TOML:
+		nd, err := tomlNode(d)
+		if err != nil {
+			return nil, err
+		}
+		return []*yaml.Node{nd}, nil
+	}
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(d))
+	for {
+		nd := &yaml.Node{}
+		err := dec.Decode(nd)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, nd)
+	}
+}
+
+// Unmarshal decodes the single document of the file at location into v.
+func Unmarshal(location string, d []byte, v interface{}) error {
+	docs, err := Documents(location, d)
+	if err != nil || len(docs) == 0 {
+		return err
+	}
+	return docs[0].Decode(v)
+}
@@ -0,0 +1,109 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+type manifest struct {
+	Variables map[string]string `yaml:"variables"`
+	Steps     []struct {
+		Cmd     string `yaml:"cmd"`
+		Retries int    `yaml:"retries"`
+	} `yaml:"steps"`
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		location string
+		content  string
+		want     Format
+	}{
+		{location: "m.json", content: "steps: []", want: JSON},
+		{location: "https://example.com/m.TOML?sig=1", want: TOML},
+		{location: "/etc/bruce/m.yml", content: "{}", want: YAML},
+		{location: "/etc/bruce/m", content: "\n  {\"steps\": []}", want: JSON},
+		{location: "/etc/bruce/m", content: "[\n  1\n]", want: JSON},
+		{location: "/etc/bruce/m", content: "# comment\n[[steps]]\ncmd = \"x\"", want: TOML},
+		{location: "/etc/bruce/m", content: "workers = 2", want: TOML},
+		{location: "/etc/bruce/m", content: "---\nsteps:\n  - cmd: x", want: YAML},
+		{location: "/etc/bruce/m", content: "", want: YAML},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.location, []byte(tt.content)); got != tt.want {
+			t.Errorf("Detect(%q, %q) = %s, want %s", tt.location, tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		content  string
+	}{
+		{name: "yaml", location: "m.yml", content: "variables:\n  A: a\nsteps:\n  - cmd: echo a\n    retries: 2\n"},
+		{name: "json", location: "m.json", content: "{\"variables\": {\"A\": \"a\"},\n\t\"steps\": [{\"cmd\": \"echo a\", \"retries\": 2}]}"},
+		{name: "toml", location: "m.toml", content: "[variables]\nA = \"a\"\n\n[[steps]]\ncmd = \"echo a\"\nretries = 2\n"},
+		{name: "toml inline", location: "m.toml", content: "variables = {A = \"a\"}\nsteps = [{cmd = \"echo a\", retries = 2}]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := manifest{}
+			if err := Unmarshal(tt.location, []byte(tt.content), &m); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if m.Variables["A"] != "a" || len(m.Steps) != 1 || m.Steps[0].Cmd != "echo a" || m.Steps[0].Retries != 2 {
+				t.Errorf("Unmarshal() = %+v", m)
+			}
+		})
+	}
+}
+
+func TestDocumentLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		content  string
+	}{
+		{name: "json", location: "m.json", content: "{\n  \"steps\": [\n    {\"cmd\": \"a\"},\n    {\"cmd\": \"b\"}\n  ]\n}"},
+		{name: "toml", location: "m.toml", content: "# steps\n[[steps]]\ncmd = \"a\"\n\n[[steps]]\ncmd = \"b\"\n"},
+	}
+	want := map[string][]int{"json": {3, 4}, "toml": {2, 5}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := Documents(tt.location, []byte(tt.content))
+			if err != nil {
+				t.Fatalf("Documents() error = %v", err)
+			}
+			steps := docs[0].Content[0].Content[1].Content
+			for i, nd := range steps {
+				if nd.Line != want[tt.name][i] {
+					t.Errorf("Documents() step %d line = %d, want %d", i+1, nd.Line, want[tt.name][i])
+				}
+			}
+		})
+	}
+}
+
+func TestDocumentErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		content  string
+		wantErr  string
+	}{
+		{name: "json syntax", location: "m.json", content: "{\n  \"steps\": [,]\n}", wantErr: "json: line 2"},
+		{name: "json trailing", location: "m.json", content: "{} {}", wantErr: "unexpected content"},
+		{name: "json truncated", location: "m.json", content: "{\"steps\": [", wantErr: "unexpected end"},
+		{name: "toml syntax", location: "m.toml", content: "steps = [\n", wantErr: "toml:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Documents(tt.location, []byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Documents() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
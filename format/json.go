@@ -0,0 +1,109 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// jsonNode converts a JSON document to a yaml document node, keeping the key order and line of every value.
+func jsonNode(d []byte) (*yaml.Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.UseNumber()
+	c := &jsonConverter{dec: dec, lines: lineOffsets(d)}
+	nd, err := c.value()
+	if err != nil {
+		return nil, c.wrap(err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("json: line %d: unexpected content after the document", c.line())
+	}
+	return &yaml.Node{Kind: yaml.DocumentNode, Line: nd.Line, Content: []*yaml.Node{nd}}, nil
+}
+
+type jsonConverter struct {
+	dec   *json.Decoder
+	lines []int64
+}
+
+// line returns the line of the last token read.
+func (c *jsonConverter) line() int {
+	return sort.Search(len(c.lines), func(i int) bool { return c.lines[i] >= c.dec.InputOffset() }) + 1
+}
+
+func (c *jsonConverter) wrap(err error) error {
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		return fmt.Errorf("json: line %d: %s", sort.Search(len(c.lines), func(i int) bool { return c.lines[i] >= se.Offset })+1, se.Error())
+	}
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("json: unexpected end of document")
+	}
+	return fmt.Errorf("json: %w", err)
+}
+
+func (c *jsonConverter) value() (*yaml.Node, error) {
+	tok, err := c.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	line := c.line()
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			nd := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: line}
+			for c.dec.More() {
+				k, err := c.dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k.(string), Line: c.line()}
+				v, err := c.value()
+				if err != nil {
+					return nil, err
+				}
+				nd.Content = append(nd.Content, key, v)
+			}
+			_, err := c.dec.Token()
+			return nd, err
+		}
+		nd := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: line}
+		for c.dec.More() {
+			v, err := c.value()
+			if err != nil {
+				return nil, err
+			}
+			nd.Content = append(nd.Content, v)
+		}
+		_, err := c.dec.Token()
+		return nd, err
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t, Line: line}, nil
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(t.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: t.String(), Line: line}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(t), Line: line}, nil
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null", Line: line}, nil
+}
+
+// lineOffsets returns the offset of every line break in d.
+func lineOffsets(d []byte) []int64 {
+	var offsets []int64
+	for i, b := range d {
+		if b == '\n' {
+			offsets = append(offsets, int64(i))
+		}
+	}
+	return offsets
+}
@@ -0,0 +1,120 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// tomlArrayTable matches the header of an array of tables entry, eg: [[steps]].
+var tomlArrayTable = regexp.MustCompile(`(?m)^\s*\[\[\s*([A-Za-z0-9_\-]+)\s*\]\]`)
+
+// tomlNode converts a TOML document to a yaml document node. The keys keep the order they are defined in, TOML
+// does not report the position of values so the entries of top level arrays of tables (eg: [[steps]]) carry the
+// line of their header and the other values the line of the entry holding them.
+func tomlNode(d []byte) (*yaml.Node, error) {
+	var v map[string]interface{}
+	md, err := toml.Decode(string(d), &v)
+	if err != nil {
+		return nil, fmt.Errorf("toml: %w", err)
+	}
+	c := &tomlConverter{order: make(map[string][]string), headers: make(map[string][]int)}
+	for _, k := range md.Keys() {
+		parent, name := k[:len(k)-1].String(), k[len(k)-1]
+		if !contains(c.order[parent], name) {
+			c.order[parent] = append(c.order[parent], name)
+		}
+	}
+	for _, m := range tomlArrayTable.FindAllSubmatchIndex(d, -1) {
+		name := string(d[m[2]:m[3]])
+		c.headers[name] = append(c.headers[name], lineOf(d, m[2]))
+	}
+	nd := c.mapping(nil, v, 1)
+	return &yaml.Node{Kind: yaml.DocumentNode, Line: 1, Content: []*yaml.Node{nd}}, nil
+}
+
+type tomlConverter struct {
+	order   map[string][]string
+	headers map[string][]int
+}
+
+func (c *tomlConverter) mapping(key toml.Key, m map[string]interface{}, line int) *yaml.Node {
+	nd := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: line}
+	keys := c.order[key.String()]
+	// the keys of inline tables within arrays are not reported by the decoder, they are added in sorted order
+	var rest []string
+	for k := range m {
+		if !contains(keys, k) {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	for _, k := range append(append([]string{}, keys...), rest...) {
+		v, ok := m[k]
+		if !ok {
+			continue
+		}
+		child := append(append(toml.Key{}, key...), k)
+		nd.Content = append(nd.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k, Line: line}, c.value(child, v, line))
+	}
+	return nd
+}
+
+func (c *tomlConverter) value(key toml.Key, v interface{}, line int) *yaml.Node {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return c.mapping(key, t, line)
+	case []map[string]interface{}:
+		nd := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: line}
+		for i, m := range t {
+			l := line
+			if h := c.headers[key.String()]; i < len(h) {
+				l = h[i]
+			}
+			nd.Content = append(nd.Content, c.mapping(key, m, l))
+		}
+		return nd
+	case []interface{}:
+		nd := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: line}
+		for _, e := range t {
+			nd.Content = append(nd.Content, c.value(key, e, line))
+		}
+		return nd
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t, Line: line}
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(t, 10), Line: line}
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(t, 'g', -1, 64), Line: line}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(t), Line: line}
+	case time.Time:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: t.Format(time.RFC3339Nano), Line: line}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(v), Line: line}
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// lineOf returns the line of the byte offset in d.
+func lineOf(d []byte, offset int) int {
+	line := 1
+	for _, b := range d[:offset] {
+		if b == '\n' {
+			line++
+		}
+	}
+	return line
+}
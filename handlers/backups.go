@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"bruce/backup"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ListBackups prints the runs with backups in dir (or the default backup directory) along with the files they hold.
+func ListBackups(dir string) error {
+	if len(dir) == 0 {
+		dir = backup.DefaultDir()
+	}
+	runs, err := backup.List(dir)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not list backups in: %s", dir)
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Printf("no backups found in: %s\n", dir)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, r := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d file(s)\n", r.RunID, r.Started.Local().Format(time.DateTime), r.Source, len(r.Entries))
+		for _, e := range r.Entries {
+			state := "modified"
+			if e.Created {
+				state = "created"
+			}
+			fmt.Fprintf(w, "\t%s\t%s\t\n", state, e.Path)
+		}
+	}
+	return w.Flush()
+}
+
+// RestoreBackup returns a single file to the state it had before a run, the most recent run is used when runID is empty.
+func RestoreBackup(dir, path, runID string) error {
+	if len(dir) == 0 {
+		dir = backup.DefaultDir()
+	}
+	if len(path) == 0 {
+		return fmt.Errorf("a path to restore is required")
+	}
+	idx, err := backup.Restore(dir, path, runID)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not restore: %s", path)
+		return err
+	}
+	log.Info().Msgf("restored %s from run %s (%s)", path, idx.RunID, idx.Started.Local().Format(time.DateTime))
+	return nil
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bruce/changes"
+	"bruce/config"
+	"bruce/system"
+	"bruce/vars"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Checkpoint records the progress of an install so a failed run can be resumed from the failing step.
+type Checkpoint struct {
+	Source    string            `json:"source"`
+	Hash      string            `json:"hash"`
+	Completed []int             `json:"completed"`
+	Env       map[string]string `json:"env"`
+	Changes   []changes.Change  `json:"changes,omitempty"`
+	Notified  []string          `json:"notified,omitempty"`
+	Updated   time.Time         `json:"updated"`
+	baseEnv   map[string]string
+	scope     *vars.Scope
+	registry  *changes.Registry
+	fileName  string
+}
+
+// newCheckpoint starts an empty checkpoint for the manifest.
+func newCheckpoint(t *config.TemplateData) *Checkpoint {
+	return &Checkpoint{
+		Source:   t.Source,
+		Hash:     t.Hash,
+		Env:      make(map[string]string),
+		fileName: checkpointFile(t.Source),
+	}
+}
+
+// loadCheckpoint reads the checkpoint for the manifest, returning nil if there is none.
+// An error is returned when the manifest content changed since the checkpoint was written.
+func loadCheckpoint(t *config.TemplateData) (*Checkpoint, error) {
+	fileName := checkpointFile(t.Source)
+	d, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(d, cp); err != nil {
+		return nil, fmt.Errorf("could not read checkpoint %s: %w", fileName, err)
+	}
+	if cp.Hash != t.Hash {
+		return nil, fmt.Errorf("manifest %s changed since the checkpoint was written, refusing to resume (run without --resume to start over)", t.Source)
+	}
+	if cp.Env == nil {
+		cp.Env = make(map[string]string)
+	}
+	cp.fileName = fileName
+	return cp, nil
+}
+
+// restore sets the env vars exported by the completed steps in the run scope and their changes in the registry so
+// the remaining steps still react to them. It returns the set of completed step indices, the scope variables before
+// the restore are the baseline for exported env vars.
+func (cp *Checkpoint) restore(scope *vars.Scope, reg *changes.Registry) map[int]bool {
+	cp.scope = scope
+	cp.registry = reg
+	cp.baseEnv = scope.Values()
+	for k, v := range cp.Env {
+		log.Debug().Msgf("restoring env var: %s", k)
+		scope.Set(k, v)
+	}
+	reg.Add(cp.Changes...)
+	done := make(map[int]bool)
+	for _, idx := range cp.Completed {
+		done[idx] = true
+	}
+	return done
+}
+
+// complete records the step as done along with any env vars it exported and the changes so far, then saves the
+// checkpoint.
+func (cp *Checkpoint) complete(idx int) {
+	cp.Completed = append(cp.Completed, idx)
+	for k, v := range cp.scope.Values() {
+		if bv, ok := cp.baseEnv[k]; !ok || bv != v {
+			cp.Env[k] = v
+		}
+	}
+	cp.Changes = cp.registry.All()
+	cp.Updated = time.Now()
+	if err := cp.save(); err != nil {
+		log.Warn().Err(err).Msgf("could not save checkpoint: %s", cp.fileName)
+	}
+}
+
+func (cp *Checkpoint) save() error {
+	d, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cp.fileName), 0700); err != nil {
+		return err
+	}
+	// exported env vars may hold secrets so the checkpoint is only readable by the owner
+	return os.WriteFile(cp.fileName, d, 0600)
+}
+
+// remove deletes the checkpoint once the run completed successfully.
+func (cp *Checkpoint) remove() {
+	if err := os.Remove(cp.fileName); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warn().Err(err).Msgf("could not remove checkpoint: %s", cp.fileName)
+	}
+}
+
+// checkpointFile returns the checkpoint location for a manifest source within the state directory.
+func checkpointFile(source string) string {
+	if abs, err := filepath.Abs(source); err == nil && !strings.Contains(source, "://") {
+		source = abs
+	}
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(system.StateDir(), "checkpoints", hex.EncodeToString(sum[:8])+".json")
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"bruce/changes"
+	"bruce/config"
+	"bruce/vars"
+	"os"
+	"testing"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	t.Setenv("BRUCE_STATE_DIR", t.TempDir())
+	td := &config.TemplateData{Source: "/tmp/manifest.yml", Hash: "abc"}
+
+	cp := newCheckpoint(td)
+	cp.restore(vars.New(map[string]string{"BRUCE_CHECKPOINT_BASE": "base"}), changes.New())
+	cp.scope.Set("BRUCE_CHECKPOINT_TEST", "exported")
+	cp.registry.Record(1, "/etc/app.conf")
+	cp.complete(1)
+	cp.complete(2)
+	if _, ok := cp.Env["BRUCE_CHECKPOINT_BASE"]; ok {
+		t.Errorf("complete() recorded a variable that was not exported by a step")
+	}
+
+	loaded, err := loadCheckpoint(td)
+	if err != nil || loaded == nil {
+		t.Fatalf("loadCheckpoint() = %v, %v", loaded, err)
+	}
+	scope := vars.New(nil)
+	reg := changes.New()
+	done := loaded.restore(scope, reg)
+	if !done[1] || !done[2] || done[3] {
+		t.Errorf("restore() completed = %v, want steps 1 and 2", done)
+	}
+	if v := scope.Get("BRUCE_CHECKPOINT_TEST"); v != "exported" {
+		t.Errorf("restore() env = %q, want exported", v)
+	}
+	if _, ok := os.LookupEnv("BRUCE_CHECKPOINT_TEST"); ok {
+		t.Errorf("restore() should not set the process environment")
+	}
+	if !reg.Changed("/etc/app.conf") {
+		t.Errorf("restore() changes = %v, want /etc/app.conf", reg.All())
+	}
+
+	changed := &config.TemplateData{Source: td.Source, Hash: "def"}
+	if _, err := loadCheckpoint(changed); err == nil {
+		t.Errorf("loadCheckpoint() should refuse a changed manifest")
+	}
+
+	loaded.remove()
+	if cp, err := loadCheckpoint(td); cp != nil || err != nil {
+		t.Errorf("loadCheckpoint() after remove = %v, %v", cp, err)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bruce/config"
+	"bruce/format"
+	"bruce/loader"
+	"bruce/lock"
+	"bruce/operators"
+	"bruce/vars"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RunOptions configures a single execution of a manifest, they are set from the cli flags for installs and from the
+// server configuration for cadence and event executions.
+type RunOptions struct {
+	Action       string
+	Trigger      string
+	PropertyFile string
+	Variables    map[string]string
+	ReportFile   string
+	Checkpoint   bool
+	Resume       bool
+	LockMode     string
+	LockFile     string
+	Workers      int
+	Filter       StepFilter
+}
+
+// executionOptions returns the run options for a server execution.
+func executionOptions(e config.Execution) RunOptions {
+	return RunOptions{
+		Action:       e.Name,
+		Trigger:      e.Type,
+		PropertyFile: e.PropertyFile,
+		Variables:    e.Variables,
+		ReportFile:   e.Report,
+		LockMode:     e.Lock,
+		Workers:      e.Workers,
+		Filter: StepFilter{
+			Tags:     e.Tags,
+			SkipTags: e.SkipTags,
+			StartAt:  e.StartAtStep,
+			Names:    e.StepNames,
+		},
+	}
+}
+
+// Run is the execution engine used by every entry point. Runs hold the host wide run lock so they never overlap and
+// are recorded in the local run history once they complete.
+// The manifest variables, the property file and the variable overrides are layered (in that order) into a variable
+// scope owned by the run, the process environment is never modified so executions in server mode do not leak
+// variables into each other.
+func Run(ctx context.Context, t *config.TemplateData, opts RunOptions) (*Report, []*StepResult, error) {
+	report := NewReport(t, opts.Action, opts.Trigger)
+	lc := log.Ctx(ctx).With().Str("runId", report.RunID)
+	if len(opts.Action) > 0 {
+		lc = lc.Str("action", opts.Action)
+	}
+	ctx = lc.Logger().WithContext(ctx)
+	log.Ctx(ctx).Debug().Str("source", t.Source).Msg("starting run")
+	// every run is recorded, including the ones that could not start, a dry run changes nothing so it is not recorded
+	// and does not rotate the history of the runs that did
+	if !operators.DryRun() {
+		defer recordHistory(report)
+	}
+	l, err := acquireLock(ctx, t, opts)
+	if errors.Is(err, lock.ErrLocked) && opts.LockMode == lock.ModeSkip {
+		log.Ctx(ctx).Info().Msg("run skipped, another run holds the lock")
+		writeReport(opts.ReportFile, report.Skip("another run holds the lock"))
+		return report, nil, nil
+	}
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("could not acquire the run lock")
+		return report.Finish(nil, err), nil, err
+	}
+	defer l.Release()
+	log.Ctx(ctx).Debug().Msgf("propfile: %s", opts.PropertyFile)
+	props, err := loadPropData(opts.PropertyFile)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot proceed without the properties file specified.")
+		return report.Finish(nil, err), nil, err
+	}
+	values := make(map[string]string)
+	for _, m := range []map[string]string{t.Variables, props, opts.Variables} {
+		for k, v := range m {
+			log.Ctx(ctx).Debug().Msgf("setting variable: %s=%s", k, v)
+			values[k] = v
+		}
+	}
+	ctx = vars.WithScope(ctx, vars.New(values))
+
+	var cp *Checkpoint
+	if opts.Resume {
+		cp, err = loadCheckpoint(t)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cannot resume install")
+			return report.Finish(nil, err), nil, err
+		}
+		if cp == nil {
+			log.Ctx(ctx).Info().Msgf("no checkpoint found for %s, starting from the first step", t.Source)
+		} else {
+			log.Ctx(ctx).Info().Msgf("resuming %s with %d completed step(s)", t.Source, len(cp.Completed))
+		}
+	}
+	if cp == nil && opts.Checkpoint {
+		cp = newCheckpoint(t)
+	}
+
+	if opts.Workers > 0 {
+		// the manifest may be shared by several executions so the override is applied to a copy
+		tc := *t
+		tc.Workers = opts.Workers
+		t = &tc
+	}
+	results, err := runSteps(ctx, t, report.RunID, cp, opts.Filter)
+	writeReport(opts.ReportFile, report.Finish(results, err))
+	if cp != nil && err == nil && !operators.DryRun() {
+		cp.remove()
+	}
+	return report, results, err
+}
+
+// acquireLock takes the run lock for the manifest according to the lock mode of the options.
+func acquireLock(ctx context.Context, t *config.TemplateData, opts RunOptions) (*lock.Lock, error) {
+	lockFile := opts.LockFile
+	if len(lockFile) == 0 {
+		lockFile = lock.Path()
+	}
+	action := opts.Action
+	if len(action) == 0 {
+		action = "install"
+	}
+	l, err := lock.Acquire(ctx, lockFile, opts.LockMode, lock.Holder{PID: os.Getpid(), Action: action, Source: t.Source, Started: time.Now()})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", lockFile, err)
+	}
+	return l, nil
+}
+
+// loadPropData loads the property data from the property file.
+func loadPropData(propFile string) (map[string]string, error) {
+	if len(propFile) < 1 {
+		return nil, nil
+	}
+	// read content of property file and unmarshal into map
+	d, _, err := loader.ReadRemoteFile(propFile)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Bytes("rawConfig", d)
+	c := make(map[string]string)
+
+	err = format.Unmarshal(propFile, d, &c)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse property file %s: %w", propFile, err)
+	}
+	return c, nil
+}
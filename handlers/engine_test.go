@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bruce/config"
+	"bruce/lock"
+	"bruce/operators"
+	"bruce/vars"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// envOperator records the value of an env var when executed.
+type envOperator struct {
+	name  string
+	value string
+}
+
+func (e *envOperator) Execute(ctx context.Context) (*operators.Result, error) {
+	e.value = vars.FromContext(ctx).Get(e.name)
+	return operators.Ok("read %s", e.name), nil
+}
+
+func TestRunVariables(t *testing.T) {
+	t.Setenv("BRUCE_STATE_DIR", t.TempDir())
+	propFile := filepath.Join(t.TempDir(), "props.yml")
+	if err := os.WriteFile(propFile, []byte("FROM_PROPS: props\nOVERRIDDEN: props\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ops := map[string]*envOperator{}
+	td := &config.TemplateData{
+		BackupDir: t.TempDir(),
+		Variables: map[string]string{"FROM_MANIFEST": "manifest", "OVERRIDDEN": "manifest"},
+	}
+	for _, name := range []string{"FROM_MANIFEST", "FROM_PROPS", "OVERRIDDEN"} {
+		ops[name] = &envOperator{name: name}
+		td.Steps = append(td.Steps, config.Steps{Action: ops[name]})
+	}
+	opts := executionOptions(config.Execution{
+		Name:         "test",
+		PropertyFile: propFile,
+		Variables:    map[string]string{"OVERRIDDEN": "execution"},
+	})
+	if _, _, err := Run(context.Background(), td, opts); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := map[string]string{"FROM_MANIFEST": "manifest", "FROM_PROPS": "props", "OVERRIDDEN": "execution"}
+	for name, v := range want {
+		if ops[name].value != v {
+			t.Errorf("Run() %s = %q, want %q", name, ops[name].value, v)
+		}
+		if _, ok := os.LookupEnv(name); ok {
+			t.Errorf("Run() %s should not be set in the process environment", name)
+		}
+	}
+}
+
+func TestRunLockModes(t *testing.T) {
+	t.Setenv("BRUCE_STATE_DIR", t.TempDir())
+	lockFile := filepath.Join(t.TempDir(), "bruce.lock")
+	held, err := lock.Acquire(context.Background(), lockFile, lock.ModeFail, lock.Holder{PID: os.Getpid(), Action: "held"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Release()
+	tests := []struct {
+		name       string
+		mode       string
+		wantErr    bool
+		wantStatus string
+	}{
+		{name: "skip", mode: lock.ModeSkip, wantStatus: "skipped"},
+		{name: "fail", mode: lock.ModeFail, wantErr: true, wantStatus: "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := &envOperator{name: "UNUSED"}
+			td := &config.TemplateData{BackupDir: t.TempDir(), Steps: []config.Steps{{Action: op}}}
+			report, results, err := Run(context.Background(), td, RunOptions{LockMode: tt.mode, LockFile: lockFile})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(results) != 0 {
+				t.Errorf("Run() executed %d step(s) while the lock was held", len(results))
+			}
+			if report.Status != tt.wantStatus {
+				t.Errorf("Run() status = %q, want %q", report.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRunManifestTwice(t *testing.T) {
+	t.Setenv("BRUCE_STATE_DIR", t.TempDir())
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.tmpl")
+	if err := os.WriteFile(src, []byte("name: {{.NAME}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the same manifest is executed on every run, as the cadence runner does
+	td := &config.TemplateData{
+		BackupDir: t.TempDir(),
+		Steps: []config.Steps{
+			{Action: &operators.Template{Template: filepath.Join(dir, "${NAME}.conf"), RemoteLoc: src}},
+			{Action: &operators.Copy{Src: filepath.Join(dir, "${NAME}.conf"), Dest: filepath.Join(dir, "${NAME}.copy")}},
+		},
+	}
+	for _, name := range []string{"first", "second"} {
+		opts := RunOptions{Variables: map[string]string{"NAME": name}, LockFile: filepath.Join(t.TempDir(), "bruce.lock")}
+		if _, _, err := Run(context.Background(), td, opts); err != nil {
+			t.Fatalf("Run() %s error = %v", name, err)
+		}
+		for _, f := range []string{name + ".conf", name + ".copy"} {
+			if d, err := os.ReadFile(filepath.Join(dir, f)); err != nil || string(d) != "name: "+name {
+				t.Errorf("Run() %s = %q (%v), want the values of the %s run", f, d, err, name)
+			}
+		}
+	}
+}
+
+func TestStepEnv(t *testing.T) {
+	t.Setenv("BRUCE_STATE_DIR", t.TempDir())
+	op := &envOperator{name: "STEP_VALUE"}
+	after := &envOperator{name: "STEP_VALUE"}
+	td := &config.TemplateData{
+		BackupDir: t.TempDir(),
+		Variables: map[string]string{"RUN_VALUE": "run"},
+		Steps: []config.Steps{
+			{Action: op, Env: map[string]string{"STEP_VALUE": "step-${RUN_VALUE}"}},
+			{Action: after},
+		},
+	}
+	if _, _, err := Run(context.Background(), td, RunOptions{LockFile: filepath.Join(t.TempDir(), "bruce.lock")}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if op.value != "step-run" {
+		t.Errorf("Run() step env = %q, want step-run", op.value)
+	}
+	if after.value != "" {
+		t.Errorf("Run() step env leaked into the next step: %q", after.value)
+	}
+}
+
+func TestRoleVariables(t *testing.T) {
+	t.Setenv("BRUCE_STATE_DIR", t.TempDir())
+	ops := map[string]*envOperator{}
+	for _, name := range []string{"ROLE_DEFAULT", "ROLE_OVERRIDDEN", "ROLE_PARAM"} {
+		ops[name] = &envOperator{name: name}
+	}
+	after := &envOperator{name: "ROLE_PARAM"}
+	defaults := map[string]string{"ROLE_DEFAULT": "default", "ROLE_OVERRIDDEN": "default"}
+	with := map[string]string{"ROLE_PARAM": "param-${RUN_VALUE}"}
+	td := &config.TemplateData{
+		BackupDir: t.TempDir(),
+		Variables: map[string]string{"RUN_VALUE": "run", "ROLE_OVERRIDDEN": "run"},
+		Steps: []config.Steps{
+			{Action: ops["ROLE_DEFAULT"], Defaults: defaults, With: with},
+			{Action: ops["ROLE_OVERRIDDEN"], Defaults: defaults, With: with},
+			{Action: ops["ROLE_PARAM"], Defaults: defaults, With: with},
+			{Action: after},
+		},
+	}
+	if _, _, err := Run(context.Background(), td, RunOptions{LockFile: filepath.Join(t.TempDir(), "bruce.lock")}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := map[string]string{"ROLE_DEFAULT": "default", "ROLE_OVERRIDDEN": "run", "ROLE_PARAM": "param-run"}
+	for name, v := range want {
+		if ops[name].value != v {
+			t.Errorf("Run() %s = %q, want %q", name, ops[name].value, v)
+		}
+	}
+	if after.value != "" {
+		t.Errorf("Run() role parameters leaked into a step outside the role: %q", after.value)
+	}
+}
+
+func TestLoadPropData(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"props.yml":  "HOST: example\nPORT: 8080\n",
+		"props.json": "{\"HOST\": \"example\", \"PORT\": 8080}",
+		"props.toml": "HOST = \"example\"\nPORT = 8080\n",
+		"props":      "# sniffed\nHOST = \"example\"\nPORT = 8080\n",
+	}
+	for name, content := range files {
+		fn := filepath.Join(dir, name)
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Run(name, func(t *testing.T) {
+			got, err := loadPropData(fn)
+			if err != nil {
+				t.Fatalf("loadPropData() error = %v", err)
+			}
+			if got["HOST"] != "example" || got["PORT"] != "8080" {
+				t.Errorf("loadPropData() = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bruce/config"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StepFilter selects the steps of a manifest to run, the steps it leaves out are reported as skipped.
+type StepFilter struct {
+	// Tags only runs the steps with at least one of the tags.
+	Tags []string
+	// SkipTags leaves out the steps with any of the tags.
+	SkipTags []string
+	// StartAt leaves out the steps before the given step number, id or name.
+	StartAt string
+	// Names only runs the steps with one of the names.
+	Names []string
+}
+
+// exclusions returns the reason each left out step is skipped for, keyed by step index.
+// An error is returned when the start step or a step name does not match any step.
+func (f StepFilter) exclusions(steps config.StepList) (map[int]string, error) {
+	excluded := make(map[int]string)
+	if len(f.StartAt) > 0 {
+		start, err := findStep(steps, f.StartAt)
+		if err != nil {
+			return nil, fmt.Errorf("start at step: %w", err)
+		}
+		for idx := 0; idx < start; idx++ {
+			excluded[idx] = fmt.Sprintf("before start step [%d]", start+1)
+		}
+	}
+	names := make(map[string]bool)
+	for _, n := range f.Names {
+		names[n] = true
+	}
+	for n := range names {
+		found := false
+		for _, s := range steps {
+			found = found || s.Name == n
+		}
+		if !found {
+			return nil, fmt.Errorf("no step named %q", n)
+		}
+	}
+	for idx, s := range steps {
+		if _, ok := excluded[idx]; ok {
+			continue
+		}
+		if len(names) > 0 && !names[s.Name] {
+			excluded[idx] = "not selected by step name"
+			continue
+		}
+		if len(f.Tags) > 0 && len(matchingTags(s.Tags, f.Tags)) == 0 {
+			excluded[idx] = fmt.Sprintf("not tagged with: %s", strings.Join(f.Tags, ", "))
+			continue
+		}
+		if skip := matchingTags(s.Tags, f.SkipTags); len(skip) > 0 {
+			excluded[idx] = fmt.Sprintf("skip tags: %s", strings.Join(skip, ", "))
+		}
+	}
+	return excluded, nil
+}
+
+// findStep returns the index of the step matching a step number, id or name.
+func findStep(steps config.StepList, ref string) (int, error) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(steps) {
+			return 0, fmt.Errorf("step [%d] does not exist, the manifest has %d steps", n, len(steps))
+		}
+		return n - 1, nil
+	}
+	for idx, s := range steps {
+		if s.ID == ref {
+			return idx, nil
+		}
+	}
+	for idx, s := range steps {
+		if s.Name == ref {
+			return idx, nil
+		}
+	}
+	return 0, fmt.Errorf("no step with id or name %q", ref)
+}
+
+// matchingTags returns the tags found in both lists.
+func matchingTags(tags, want []string) []string {
+	var found []string
+	for _, w := range want {
+		for _, t := range tags {
+			if t == w {
+				found = append(found, w)
+				break
+			}
+		}
+	}
+	return found
+}
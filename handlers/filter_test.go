@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"bruce/config"
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestStepFilterExclusions(t *testing.T) {
+	steps := config.StepList{
+		{Name: "packages", Tags: []string{"packages"}},
+		{ID: "conf", Name: "nginx config", Tags: []string{"config", "nginx"}},
+		{Name: "deploy", Tags: []string{"app"}},
+		{Name: "cleanup"},
+	}
+	tests := []struct {
+		name    string
+		filter  StepFilter
+		want    []int
+		wantErr bool
+	}{
+		{name: "no filter", filter: StepFilter{}},
+		{name: "tags", filter: StepFilter{Tags: []string{"config", "app"}}, want: []int{0, 3}},
+		{name: "skip tags", filter: StepFilter{SkipTags: []string{"nginx"}}, want: []int{1}},
+		{name: "start at number", filter: StepFilter{StartAt: "3"}, want: []int{0, 1}},
+		{name: "start at id", filter: StepFilter{StartAt: "conf"}, want: []int{0}},
+		{name: "start at name", filter: StepFilter{StartAt: "cleanup"}, want: []int{0, 1, 2}},
+		{name: "step names", filter: StepFilter{Names: []string{"deploy"}}, want: []int{0, 1, 3}},
+		{name: "combined", filter: StepFilter{StartAt: "2", SkipTags: []string{"app"}}, want: []int{0, 2}},
+		{name: "unknown start", filter: StepFilter{StartAt: "nope"}, wantErr: true},
+		{name: "start out of range", filter: StepFilter{StartAt: "9"}, wantErr: true},
+		{name: "unknown step name", filter: StepFilter{Names: []string{"nope"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.filter.exclusions(steps)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("exclusions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var idx []int
+			for i := range got {
+				idx = append(idx, i)
+			}
+			sort.Ints(idx)
+			if !reflect.DeepEqual(idx, tt.want) {
+				t.Errorf("exclusions() = %v, want %v", idx, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunStepsFilter(t *testing.T) {
+	ops := []*flakyOperator{{succeedOn: 1}, {succeedOn: 1}, {succeedOn: 1}}
+	td := &config.TemplateData{BackupDir: t.TempDir(), Steps: config.StepList{
+		{Action: ops[0], Tags: []string{"packages"}},
+		{Action: ops[1], Tags: []string{"config"}},
+		{Action: ops[2], Tags: []string{"packages"}},
+	}}
+	results, err := runSteps(context.Background(), td, "test", nil, StepFilter{Tags: []string{"packages"}})
+	if err != nil {
+		t.Fatalf("runSteps() error = %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("runSteps() = %d results, skipped steps should be reported", len(results))
+	}
+	if results[1].Status != "skipped" || ops[1].calls != 0 {
+		t.Errorf("runSteps() step [2] status = %s, want skipped", results[1].Status)
+	}
+	if ops[0].calls != 1 || ops[2].calls != 1 {
+		t.Errorf("runSteps() did not run the tagged steps")
+	}
+}
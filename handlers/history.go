@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"bruce/system"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultHistoryKeep is the number of runs kept in the history when no limit is configured.
+const defaultHistoryKeep = 100
+
+var historyKeep atomic.Int64
+
+func init() {
+	historyKeep.Store(defaultHistoryKeep)
+}
+
+// SetHistoryKeep sets the number of runs kept in the local run history, older runs are removed once a run completes.
+func SetHistoryKeep(keep int) {
+	if keep <= 0 {
+		keep = defaultHistoryKeep
+	}
+	historyKeep.Store(int64(keep))
+}
+
+// historyDir returns the directory of the local run history within the state directory.
+func historyDir() string {
+	return filepath.Join(system.StateDir(), "history")
+}
+
+// recordHistory saves the report of a completed run in the local run history and rotates the older runs.
+func recordHistory(r *Report) {
+	dir := historyDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Warn().Err(err).Msgf("could not create history directory: %s", dir)
+		return
+	}
+	d, err := json.Marshal(r)
+	if err != nil {
+		log.Warn().Err(err).Msg("could not encode run history")
+		return
+	}
+	// step output may hold secrets so the history is only readable by the owner
+	if err := os.WriteFile(filepath.Join(dir, r.RunID+".json"), d, 0600); err != nil {
+		log.Warn().Err(err).Msgf("could not record run history in: %s", dir)
+		return
+	}
+	if err := pruneHistory(dir, int(historyKeep.Load())); err != nil {
+		log.Warn().Err(err).Msg("could not rotate run history")
+	}
+}
+
+// historyFiles returns the run files in dir, the most recently completed first. Run ids only have a precision of a
+// second so the files are ordered by the time they were written.
+func historyFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	written := make(map[string]time.Time)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, e.Name())
+		written[e.Name()] = info.ModTime()
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !written[files[i]].Equal(written[files[j]]) {
+			return written[files[i]].After(written[files[j]])
+		}
+		return files[i] > files[j]
+	})
+	return files, nil
+}
+
+// pruneHistory removes all but the newest keep runs from dir.
+func pruneHistory(dir string, keep int) error {
+	files, err := historyFiles(dir)
+	if err != nil || len(files) <= keep {
+		return err
+	}
+	for _, f := range files[keep:] {
+		if err := os.Remove(filepath.Join(dir, f)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func readHistory(fileName string) (*Report, error) {
+	d, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{}
+	if err := json.Unmarshal(d, r); err != nil {
+		return nil, fmt.Errorf("could not read run history %s: %w", fileName, err)
+	}
+	return r, nil
+}
+
+// loadHistory returns up to limit runs from dir, newest first. A limit of 0 returns every run.
+func loadHistory(dir string, limit int) ([]*Report, error) {
+	files, err := historyFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(files) > limit {
+		files = files[:limit]
+	}
+	var runs []*Report
+	for _, f := range files {
+		r, err := readHistory(filepath.Join(dir, f))
+		if err != nil {
+			log.Warn().Err(err).Msg("skipping unreadable run history")
+			continue
+		}
+		runs = append(runs, r)
+	}
+	return runs, nil
+}
+
+// findHistory returns the run matching id, a unique prefix of the run id is accepted.
+func findHistory(dir, id string) (*Report, error) {
+	files, err := historyFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, f := range files {
+		runID := strings.TrimSuffix(f, ".json")
+		if runID == id {
+			matches = []string{f}
+			break
+		}
+		if strings.HasPrefix(runID, id) {
+			matches = append(matches, f)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no run found with id: %s", id)
+	case 1:
+		return readHistory(filepath.Join(dir, matches[0]))
+	}
+	return nil, fmt.Errorf("run id %s is ambiguous, it matches %d runs", id, len(matches))
+}
+
+// History prints the most recent runs recorded on this host.
+func History(limit int) error {
+	runs, err := loadHistory(historyDir(), limit)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not read run history in: %s", historyDir())
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Printf("no runs recorded in: %s\n", historyDir())
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN ID\tSTARTED\tTRIGGER\tACTION\tSTATUS\tDURATION\tSOURCE")
+	for _, r := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.RunID, r.Started.Local().Format(time.DateTime), r.Trigger, r.Action, runStatus(r), duration(r), r.Source)
+	}
+	return w.Flush()
+}
+
+// ShowHistory prints the details of a single run, or the stored report when asJSON is set.
+func ShowHistory(id string, asJSON bool) error {
+	if len(id) == 0 {
+		return fmt.Errorf("a run id is required, see: bruce history")
+	}
+	r, err := findHistory(historyDir(), id)
+	if err != nil {
+		log.Error().Err(err).Msg("could not show run")
+		return err
+	}
+	if asJSON {
+		return r.Write("-")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "run:\t%s\n", r.RunID)
+	fmt.Fprintf(w, "source:\t%s\n", r.Source)
+	fmt.Fprintf(w, "trigger:\t%s\n", r.Trigger)
+	if len(r.Action) > 0 {
+		fmt.Fprintf(w, "action:\t%s\n", r.Action)
+	}
+	fmt.Fprintf(w, "host:\t%s\n", r.Host.Hostname)
+	fmt.Fprintf(w, "started:\t%s\n", r.Started.Local().Format(time.DateTime))
+	fmt.Fprintf(w, "finished:\t%s (%s)\n", r.Finished.Local().Format(time.DateTime), duration(r))
+	fmt.Fprintf(w, "status:\t%s\n", runStatus(r))
+	if len(r.Error) > 0 {
+		fmt.Fprintf(w, "error:\t%s\n", r.Error)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "STEP\tNAME\tTYPE\tSTATUS\tATTEMPTS\tDURATION\tDETAIL")
+	for _, s := range r.Steps {
+		detail := s.Reason
+		if len(s.Error) > 0 {
+			detail = s.Error
+		}
+		step := fmt.Sprintf("%d", s.Index)
+		if s.Handler {
+			step = "handler"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", step, s.Name, s.Type, s.Status, s.Attempts, time.Duration(s.Duration)*time.Millisecond, detail)
+	}
+	return w.Flush()
+}
+
+func runStatus(r *Report) string {
+	if r.DryRun {
+		return r.Status + " (dry run)"
+	}
+	return r.Status
+}
+
+func duration(r *Report) time.Duration {
+	return (time.Duration(r.Duration) * time.Millisecond).Round(time.Millisecond)
+}
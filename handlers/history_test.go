@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bruce/backup"
+	"bruce/config"
+	"bruce/operators"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunHistory(t *testing.T) {
+	t.Setenv("BRUCE_STATE_DIR", t.TempDir())
+	SetHistoryKeep(2)
+	defer SetHistoryKeep(0)
+	td := &config.TemplateData{Source: "/tmp/manifest.yml", BackupDir: t.TempDir()}
+	var ids []string
+	for i := 0; i < 3; i++ {
+		opts := RunOptions{Trigger: "cadence", LockFile: filepath.Join(t.TempDir(), "bruce.lock")}
+		report, _, err := Run(context.Background(), td, opts)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		ids = append(ids, report.RunID)
+	}
+
+	runs, err := loadHistory(historyDir(), 0)
+	if err != nil {
+		t.Fatalf("loadHistory() error = %v", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("loadHistory() = %d runs, want 2 after rotation", len(runs))
+	}
+	if runs[0].RunID != ids[2] || runs[1].RunID != ids[1] {
+		t.Errorf("loadHistory() = [%s %s], want the newest runs first [%s %s]", runs[0].RunID, runs[1].RunID, ids[2], ids[1])
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{name: "exact id", id: ids[2], want: ids[2]},
+		{name: "unique prefix", id: ids[1][:len(ids[1])-2], want: ids[1]},
+		{name: "rotated run", id: ids[0], wantErr: true},
+		{name: "unknown run", id: "nope", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := findHistory(historyDir(), tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findHistory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (r.RunID != tt.want || r.Trigger != "cadence" || r.Source != td.Source) {
+				t.Errorf("findHistory() = %s (%s, %s), want %s", r.RunID, r.Trigger, r.Source, tt.want)
+			}
+		})
+	}
+}
+
+func TestDryRunLeavesHistoryAndBackups(t *testing.T) {
+	t.Setenv("BRUCE_STATE_DIR", t.TempDir())
+	dir := t.TempDir()
+	src, dest := filepath.Join(dir, "src.conf"), filepath.Join(dir, "dest.conf")
+	for f, content := range map[string]string{src: "new", dest: "old"} {
+		if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	td := &config.TemplateData{Source: "/tmp/manifest.yml", BackupDir: t.TempDir(), Steps: []config.Steps{{Action: &operators.Copy{Src: src, Dest: dest}}}}
+	opts := RunOptions{LockFile: filepath.Join(t.TempDir(), "bruce.lock")}
+	if _, _, err := Run(context.Background(), td, opts); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// the backup of the first run has expired, only a real run may prune it
+	td.BackupMaxAge = time.Nanosecond
+	operators.SetDryRun(true)
+	defer operators.SetDryRun(false)
+	if _, _, err := Run(context.Background(), td, opts); err != nil {
+		t.Fatalf("Run() dry run error = %v", err)
+	}
+	if runs, err := loadHistory(historyDir(), 0); err != nil || len(runs) != 1 || runs[0].DryRun {
+		t.Errorf("loadHistory() = %d runs (%v), want only the real run", len(runs), err)
+	}
+	if runs, err := backup.List(td.BackupDir); err != nil || len(runs) != 1 {
+		t.Errorf("backup.List() = %d runs (%v), want the backup of the real run", len(runs), err)
+	}
+}
@@ -2,58 +2,15 @@ package handlers
 
 import (
 	"bruce/config"
-	"bruce/loader"
-	"github.com/rs/zerolog/log"
-	"gopkg.in/yaml.v3"
-	"os"
+	"context"
 )
 
-func Install(t *config.TemplateData, propfile string) error {
-	log.Debug().Msg("starting install task")
-	if len(t.Variables) > 0 {
-		for k, v := range t.Variables {
-			log.Debug().Msgf("setting env var: %s=%s", k, v)
-			os.Setenv(k, v)
-		}
-	}
-	log.Debug().Msgf("propfile: %s", propfile)
-	err := loadPropData(propfile)
-	if err != nil {
-		log.Error().Err(err).Msg("cannot proceed without the properties file specified.")
-		os.Exit(1)
-	}
-	for idx, step := range t.Steps {
-		if step.Action != nil {
-			err := step.Action.Execute()
-			if err != nil {
-				log.Error().Err(err).Msgf("error executing step [%d]", idx+1)
-				os.Exit(1)
-			}
-		}
-	}
-	return nil
-}
-
-// loadPropData loads the property data from property file and does os.SetEnv env for each property value.
-func loadPropData(propFile string) error {
-	if len(propFile) < 1 {
-		return nil
-	}
-	// read content of property file and unmarshal into map
-	d, _, err := loader.ReadRemoteFile(propFile)
-	if err != nil {
-		return err
-	}
-	log.Debug().Bytes("rawConfig", d)
-	c := make(map[string]string)
-
-	err = yaml.Unmarshal(d, c)
-	if err != nil {
-		log.Fatal().Err(err).Msg("could not parse config file")
-	}
-	for k, v := range c {
-		log.Debug().Msgf("setting env var: %s=%s", k, v)
-		os.Setenv(k, v)
-	}
-	return nil
+// Install executes the manifest steps in order, when a report file is set a JSON run report is written once the run
+// completes. An error is returned for the first failed step that does not ignore errors, progress is checkpointed so a
+// failed install can be continued from the failing step with resume.
+func Install(t *config.TemplateData, opts RunOptions) ([]*StepResult, error) {
+	opts.Checkpoint = true
+	opts.Trigger = "install"
+	_, results, err := Run(context.Background(), t, opts)
+	return results, err
 }
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bruce/config"
+	"bruce/operators"
+	"bruce/system"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Report is the machine-readable summary of a run, written as JSON for dashboards and other tooling.
+type Report struct {
+	RunID    string         `json:"runId"`
+	Action   string         `json:"action,omitempty"`
+	Trigger  string         `json:"trigger,omitempty"`
+	Source   string         `json:"source"`
+	DryRun   bool           `json:"dryRun"`
+	Host     HostFacts      `json:"host"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished"`
+	Duration int64          `json:"durationMs"`
+	Status   string         `json:"status"`
+	Error    string         `json:"error,omitempty"`
+	Summary  map[string]int `json:"summary"`
+	Steps    []StepReport   `json:"steps"`
+}
+
+// HostFacts are the details of the host the run was executed on.
+type HostFacts struct {
+	Hostname          string `json:"hostname"`
+	OSType            string `json:"osType"`
+	OSID              string `json:"osId"`
+	OSVersionID       string `json:"osVersionId"`
+	OSName            string `json:"osName"`
+	OSArch            string `json:"osArch"`
+	PackageHandler    string `json:"packageHandler"`
+	ServiceController string `json:"serviceController"`
+	User              string `json:"user"`
+}
+
+// StepReport is the outcome of a single step within the report.
+type StepReport struct {
+	Index     int      `json:"index"`
+	ID        string   `json:"id,omitempty"`
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Status    string   `json:"status"`
+	Reason    string   `json:"reason,omitempty"`
+	Duration  int64    `json:"durationMs"`
+	Attempts  int      `json:"attempts"`
+	Ignored   bool     `json:"ignored,omitempty"`
+	Handler   bool     `json:"handler,omitempty"`
+	Restored  []string `json:"rolledBack,omitempty"`
+	Files     []string `json:"changedFiles,omitempty"`
+	Resources []string `json:"changedResources,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Stdout    string   `json:"stdout,omitempty"`
+	Stderr    string   `json:"stderr,omitempty"`
+}
+
+// NewReport starts a report for the manifest, action is the server execution name and is empty for installs.
+// The trigger tells what started the run: install, cadence or event.
+func NewReport(t *config.TemplateData, action, trigger string) *Report {
+	return &Report{
+		RunID:   newRunID(),
+		Action:  action,
+		Trigger: trigger,
+		Source:  t.Source,
+		DryRun:  operators.DryRun(),
+		Host:    hostFacts(),
+		Started: time.Now(),
+		Summary: make(map[string]int),
+	}
+}
+
+// Finish records the step results and the overall outcome of the run.
+func (r *Report) Finish(results []*StepResult, err error) *Report {
+	r.Finished = time.Now()
+	r.Duration = r.Finished.Sub(r.Started).Milliseconds()
+	r.Status = "success"
+	if err != nil {
+		r.Status = "failed"
+		r.Error = err.Error()
+	}
+	for _, sr := range results {
+		step := StepReport{
+			Index:     sr.Index,
+			ID:        sr.ID,
+			Name:      sr.Name,
+			Type:      sr.Type,
+			Status:    string(sr.Status),
+			Reason:    sr.Reason,
+			Duration:  sr.Duration.Milliseconds(),
+			Attempts:  sr.Attempts,
+			Ignored:   sr.Ignored,
+			Handler:   sr.Handler,
+			Restored:  sr.RolledBack,
+			Files:     sr.Files,
+			Resources: sr.Resources,
+			Stdout:    sr.Stdout,
+			Stderr:    sr.Stderr,
+		}
+		if sr.Err != nil {
+			step.Error = sr.Err.Error()
+		}
+		r.Summary[step.Status]++
+		r.Steps = append(r.Steps, step)
+	}
+	return r
+}
+
+// Skip marks the run as skipped before any step was executed.
+func (r *Report) Skip(reason string) *Report {
+	r.Finish(nil, nil)
+	r.Status = "skipped"
+	r.Error = reason
+	return r
+}
+
+// Write saves the report as indented JSON to fileName, a file name of "-" writes to stdout.
+func (r *Report) Write(fileName string) error {
+	d, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	d = append(d, '\n')
+	if fileName == "-" {
+		_, err = os.Stdout.Write(d)
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(fileName), 0755); err != nil {
+		log.Error().Err(err).Msgf("could not create report directory for: %s", fileName)
+		return err
+	}
+	if err := os.WriteFile(fileName, d, 0644); err != nil {
+		log.Error().Err(err).Msgf("could not write report: %s", fileName)
+		return err
+	}
+	log.Info().Msgf("run report written to: %s", fileName)
+	return nil
+}
+
+// writeReport writes the report when a report file was requested, failures are logged as the run itself has completed.
+func writeReport(fileName string, r *Report) {
+	if len(fileName) == 0 {
+		return
+	}
+	if err := r.Write(fileName); err != nil {
+		log.Error().Err(err).Msg("run report was not saved")
+	}
+}
+
+func hostFacts() HostFacts {
+	s := system.Get()
+	h := HostFacts{
+		OSType:            s.OSType,
+		OSID:              s.OSID,
+		OSVersionID:       s.OSVersionID,
+		OSName:            s.OsName,
+		OSArch:            s.OSArch,
+		PackageHandler:    s.PackageHandler,
+		ServiceController: s.ServiceController,
+	}
+	h.Hostname, _ = os.Hostname()
+	if s.CurrentUser != nil {
+		h.User = s.CurrentUser.Username
+	}
+	return h
+}
+
+// newRunID returns a sortable id for the run made of the start time and a random suffix.
+func newRunID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		log.Debug().Err(err).Msg("could not read random bytes for run id")
+	}
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
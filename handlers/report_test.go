@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bruce/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRunReport(t *testing.T) {
+	t.Setenv("BRUCE_STATE_DIR", t.TempDir())
+	dir := t.TempDir()
+	src, changed, unchanged := filepath.Join(dir, "src.conf"), filepath.Join(dir, "etc", "app.conf"), filepath.Join(dir, "current.conf")
+	for _, fn := range []string{src, unchanged} {
+		if err := os.WriteFile(fn, []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := filepath.Join(dir, "manifest.yml")
+	d := fmt.Sprintf(`backupDir: %[1]s/backups
+steps:
+  - name: changed
+    copy: %[2]s
+    dest: %[3]s
+  - name: unchanged
+    copy: %[2]s
+    dest: %[4]s
+  - name: ignored
+    copy: %[1]s/missing.conf
+    dest: %[1]s/etc/missing.conf
+    ignoreErrors: true
+`, dir, src, changed, unchanged)
+	if err := os.WriteFile(manifest, []byte(d), 0644); err != nil {
+		t.Fatal(err)
+	}
+	td, err := config.LoadConfig(manifest)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	reportFile := filepath.Join(dir, "reports", "report.json")
+	if _, _, err := Run(context.Background(), td, RunOptions{ReportFile: reportFile, LockFile: filepath.Join(dir, "bruce.lock")}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	rd, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatalf("Run() did not write the report: %v", err)
+	}
+	var report Report
+	if err := json.Unmarshal(rd, &report); err != nil {
+		t.Fatalf("report is not valid json: %v", err)
+	}
+	if report.Status != "success" || report.Source != manifest || len(report.RunID) == 0 {
+		t.Errorf("report = status %q source %q run %q, want a successful run of %s", report.Status, report.Source, report.RunID, manifest)
+	}
+	if want := map[string]int{"changed": 1, "ok": 1, "failed": 1}; !reflect.DeepEqual(report.Summary, want) {
+		t.Errorf("report summary = %v, want %v", report.Summary, want)
+	}
+	tests := []struct {
+		name        string
+		wantIndex   int
+		wantStatus  string
+		wantIgnored bool
+		wantFiles   []string
+	}{
+		{name: "changed", wantIndex: 1, wantStatus: "changed", wantFiles: []string{changed}},
+		{name: "unchanged", wantIndex: 2, wantStatus: "ok"},
+		{name: "ignored", wantIndex: 3, wantStatus: "failed", wantIgnored: true},
+	}
+	if len(report.Steps) != len(tests) {
+		t.Fatalf("report has %d steps, want %d", len(report.Steps), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var step *StepReport
+			for idx := range report.Steps {
+				if report.Steps[idx].Name == tt.name {
+					step = &report.Steps[idx]
+				}
+			}
+			if step == nil {
+				t.Fatalf("report has no step named %s", tt.name)
+			}
+			if step.Index != tt.wantIndex || step.Type != "copy" || step.Status != tt.wantStatus || step.Ignored != tt.wantIgnored {
+				t.Errorf("step = %d %s %s ignored=%v, want %d copy %s ignored=%v", step.Index, step.Type, step.Status, step.Ignored, tt.wantIndex, tt.wantStatus, tt.wantIgnored)
+			}
+			if !reflect.DeepEqual(step.Files, tt.wantFiles) {
+				t.Errorf("step changedFiles = %v, want %v", step.Files, tt.wantFiles)
+			}
+			if tt.wantIgnored && len(step.Error) == 0 {
+				t.Errorf("step error is empty for a failed step")
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bruce/config"
+	"bruce/logging"
 	"context"
 	"os"
 	"os/signal"
@@ -12,7 +13,9 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func RunServer(svr_config string) error {
+// RunServer starts the cadence and event runners of the server configuration. The log section of the configuration is
+// applied with the log flags in logOpts taking precedence.
+func RunServer(svr_config string, logOpts logging.Options) error {
 	log.Debug().Msg("starting server task")
 
 	// Read server configuration
@@ -22,6 +25,10 @@ func RunServer(svr_config string) error {
 		log.Error().Err(err).Msg("cannot continue without configuration data")
 		os.Exit(1)
 	}
+	if err := logging.Setup(sc.Log.Merge(logOpts)); err != nil {
+		log.Error().Err(err).Msg("cannot configure logging")
+		os.Exit(1)
+	}
 
 	// Validate that the default action exists
 	defaultFound := false
@@ -58,7 +65,7 @@ func RunServer(svr_config string) error {
 			wg.Add(1)
 			go func(e config.Execution) {
 				defer wg.Done()
-				CadenceRunner(ctx, e.Name, e.Target, e.Cadence)
+				CadenceRunner(ctx, e)
 			}(e)
 		} else if e.Type == "event" {
 			// Add "event" type executions to the list for the SocketRunner
@@ -93,16 +100,18 @@ func RunServer(svr_config string) error {
 	return nil
 }
 
-func CadenceRunner(ctx context.Context, name, propfile string, cadence int) {
-	log.Debug().Msgf("Starting CadenceRunner[%s] with propfile: %s, every %d minutes", name, propfile, cadence)
-	t, err := config.LoadConfig(propfile)
+// CadenceRunner executes the manifest of the execution every cadence minutes.
+func CadenceRunner(ctx context.Context, e config.Execution) {
+	name := e.Name
+	log.Debug().Msgf("Starting CadenceRunner[%s] with manifest: %s, every %d minutes", name, e.Target, e.Cadence)
+	t, err := config.LoadConfig(e.Target)
 	if err != nil {
 		log.Error().Err(err).Msgf("cannot continue without configuration data, runner %s failed", name)
 		return
 	}
 
 	// Run the task at the specified cadence interval
-	ticker := time.NewTicker(time.Duration(cadence) * time.Minute)
+	ticker := time.NewTicker(time.Duration(e.Cadence) * time.Minute)
 	defer ticker.Stop()
 
 	for {
@@ -112,25 +121,17 @@ func CadenceRunner(ctx context.Context, name, propfile string, cadence int) {
 			return
 		case <-ticker.C:
 			log.Debug().Msgf("CadenceRunner[%s] running execution steps", name)
-			err = ExecuteSteps(t)
+			// a failed run (including a lost run lock) is retried on the next tick
+			report, _, err := Run(ctx, t, executionOptions(e))
 			if err != nil {
-				log.Error().Err(err).Msgf("CadenceRunner[%s] failed", name)
-				return
+				log.Error().Err(err).Msgf("CadenceRunner[%s] failed, retrying in %d minutes", name, e.Cadence)
+				continue
 			}
-			log.Info().Msgf("CadenceRunner[%s] execution succeeded", name)
-		}
-	}
-}
-
-func ExecuteSteps(t *config.TemplateData) error {
-	for idx, step := range t.Steps {
-		if step.Action != nil {
-			err := step.Action.Execute()
-			if err != nil {
-				log.Error().Err(err).Msgf("Error executing step [%d]", idx+1)
-				return err
+			if report.Status == "skipped" {
+				log.Info().Msgf("CadenceRunner[%s] execution skipped: %s", name, report.Error)
+				continue
 			}
+			log.Info().Msgf("CadenceRunner[%s] execution succeeded", name)
 		}
 	}
-	return nil
 }
@@ -101,10 +101,20 @@ func DataHandler(ctx context.Context, conn *websocket.Conn, eventExecutions []co
 					sendMessage("execute-failure", fmt.Sprintf("Cannot continue without configuration data, bad event action for: %s", actionEvent.Target), msg.Action, msg.ActionId)
 					continue
 				}
-				err = ExecuteSteps(t)
+				report, _, err := Run(ctx, t, executionOptions(actionEvent))
+				if d, rerr := json.Marshal(report); rerr == nil {
+					sendMessage("execute-report", string(d), msg.Action, msg.ActionId)
+				} else {
+					log.Error().Err(rerr).Msg("could not encode run report")
+				}
 				if err != nil {
-					log.Error().Err(err).Msg("ExecuteSteps error")
-					sendMessage("execute-failure", fmt.Sprintf("ExecuteSteps error: %s", err.Error()), msg.Action, msg.ActionId)
+					log.Error().Err(err).Msg("execution error")
+					sendMessage("execute-failure", fmt.Sprintf("execution error: %s", err.Error()), msg.Action, msg.ActionId)
+					continue
+				}
+				if report.Status == "skipped" {
+					sendMessage("execute-skipped", fmt.Sprintf("execution skipped: %s", report.Error), msg.Action, msg.ActionId)
+					continue
 				}
 				sendMessage("execute-success", "Execution completed", msg.Action, msg.ActionId)
 
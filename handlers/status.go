@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"bruce/lock"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Status prints the run holding the host wide run lock, if any.
+func Status() error {
+	h, err := lock.Status(lock.Path())
+	if err != nil {
+		log.Error().Err(err).Msgf("could not read run lock: %s", lock.Path())
+		return err
+	}
+	if h == nil {
+		fmt.Println("idle: no bruce run in progress")
+		return nil
+	}
+	fmt.Printf("running: %s (pid %d)\n", h.Action, h.PID)
+	if len(h.Source) > 0 {
+		fmt.Printf("manifest: %s\n", h.Source)
+	}
+	if !h.Started.IsZero() {
+		fmt.Printf("started: %s (%s ago)\n", h.Started.Local().Format(time.DateTime), time.Since(h.Started).Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"bruce/backup"
+	"bruce/changes"
+	"bruce/config"
+	"bruce/operators"
+	"bruce/vars"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultRetryDelay is the initial delay between retries when a step sets retries without a retryDelay.
+const defaultRetryDelay = time.Second
+
+// StepResult holds the outcome of a single manifest step.
+type StepResult struct {
+	operators.Result
+	Index      int
+	ID         string
+	Name       string
+	Type       string
+	Attempts   int
+	Ignored    bool
+	Handler    bool
+	RolledBack []string
+	Err        error
+}
+
+// runSteps executes the manifest steps once the steps they depend on completed, running up to the manifest workers
+// in parallel. The files and resources changed by each step are recorded in the change registry of the run before
+// the steps depending on it are started. No further steps are started after a failed step unless it ignores errors,
+// the steps already running are left to complete and the steps that were not started are reported as skipped. When
+// a checkpoint is provided the steps it completed are skipped and progress is saved after each step. Steps left out
+// by the filter are reported as skipped and do not hold back the steps depending on them.
+func runSteps(ctx context.Context, t *config.TemplateData, runID string, cp *Checkpoint, filter StepFilter) ([]*StepResult, error) {
+	deps, err := t.Steps.Dependencies()
+	if err != nil {
+		return nil, err
+	}
+	excluded, err := filter.exclusions(t.Steps)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("invalid step selection")
+		return nil, err
+	}
+	reg := changes.New()
+	ctx = changes.WithRegistry(ctx, reg)
+	backup.Begin(backupDir(t), runID, t.Source)
+	// a dry run takes no backups so it leaves the retention of the earlier runs alone
+	if !operators.DryRun() {
+		defer pruneBackups(t)
+	}
+	done := make(map[int]bool)
+	notified := make(map[string]bool)
+	if cp != nil {
+		done = cp.restore(vars.FromContext(ctx), reg)
+		for _, n := range cp.Notified {
+			notified[n] = true
+		}
+	}
+	workers := t.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	waiting := make([]int, len(t.Steps))
+	dependents := make([][]int, len(t.Steps))
+	var ready []int
+	for idx, ds := range deps {
+		waiting[idx] = len(ds)
+		for _, d := range ds {
+			dependents[d] = append(dependents[d], idx)
+		}
+		if len(ds) == 0 {
+			ready = append(ready, idx)
+		}
+	}
+	// release makes the steps waiting on idx ready once all of their dependencies completed
+	release := func(idx int) {
+		for _, d := range dependents[idx] {
+			waiting[d]--
+			if waiting[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+		sort.Ints(ready)
+	}
+
+	var results []*StepResult
+	var failed *StepResult
+	started := make(map[int]bool)
+	failedSteps := make(map[int]bool)
+	finished := make(chan *StepResult)
+	running := 0
+	for {
+		for failed == nil && running < workers && len(ready) > 0 {
+			idx := ready[0]
+			ready = ready[1:]
+			step := t.Steps[idx]
+			started[idx] = true
+			if step.Action == nil {
+				release(idx)
+				continue
+			}
+			sctx := stepContext(ctx, idx, step)
+			reason, skip := excluded[idx]
+			if done[idx+1] {
+				reason, skip = "completed before resume", true
+			}
+			if skip {
+				log.Ctx(sctx).Info().Str("reason", reason).Msg("step skipped")
+				sr := &StepResult{Index: idx + 1, ID: step.ID, Name: step.Name, Type: step.Type}
+				sr.Status = operators.StatusSkipped
+				sr.Reason = reason
+				results = append(results, sr)
+				release(idx)
+				continue
+			}
+			running++
+			go func(idx int, step config.Steps) {
+				finished <- executeStep(sctx, idx, step)
+			}(idx, step)
+		}
+		if running == 0 {
+			break
+		}
+		sr := <-finished
+		running--
+		results = append(results, sr)
+		if sr.Status == operators.StatusChanged {
+			reg.Record(sr.Index, sr.Changes()...)
+			for _, n := range t.Steps[sr.Index-1].Notify {
+				notified[n] = true
+			}
+		}
+		if cp != nil && (sr.Err == nil || sr.Ignored) && !operators.DryRun() {
+			cp.Notified = notifiedHandlers(notified)
+			cp.complete(sr.Index)
+		}
+		if sr.Err != nil && !sr.Ignored {
+			log.Ctx(stepContext(ctx, sr.Index-1, t.Steps[sr.Index-1])).Error().Err(sr.Err).Msg("error executing step")
+			failedSteps[sr.Index-1] = true
+			if failed == nil {
+				failed = sr
+			}
+			continue
+		}
+		release(sr.Index - 1)
+	}
+	if failed != nil {
+		results = append(results, blockedSteps(ctx, t, deps, started, failedSteps)...)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+	if failed == nil {
+		hr, hf := runHandlers(ctx, t, notified)
+		results = append(results, hr...)
+		failed = hf
+	} else if len(notified) > 0 {
+		log.Ctx(ctx).Warn().Strs("handlers", notifiedHandlers(notified)).Msg("notified handlers not run as a step failed")
+	}
+	if failed != nil {
+		if t.Rollback && !operators.DryRun() {
+			failed.RolledBack = rollback(ctx)
+			// the files of the completed steps were restored so they cannot be skipped on resume
+			if cp != nil {
+				cp.remove()
+			}
+		}
+		logSummary(ctx, results)
+		if failed.Handler {
+			return results, fmt.Errorf("handler %q: %w", failed.Name, failed.Err)
+		}
+		return results, fmt.Errorf("step [%d]: %w", failed.Index, failed.Err)
+	}
+	logSummary(ctx, results)
+	return results, nil
+}
+
+// blockedSteps returns a skipped result for every step that was not started as the run stopped after a failed step.
+// Steps depending (directly or through other steps) on a failed step give the failed step as the reason.
+func blockedSteps(ctx context.Context, t *config.TemplateData, deps [][]int, started, failedSteps map[int]bool) []*StepResult {
+	// failedDep holds the failed step each step depends on, or -1 when it does not depend on one
+	failedDep := make(map[int]int)
+	var find func(idx int) int
+	find = func(idx int) int {
+		if f, ok := failedDep[idx]; ok {
+			return f
+		}
+		failedDep[idx] = -1
+		for _, d := range deps[idx] {
+			if failedSteps[d] {
+				failedDep[idx] = d
+				break
+			}
+			if f := find(d); f >= 0 {
+				failedDep[idx] = f
+				break
+			}
+		}
+		return failedDep[idx]
+	}
+	var results []*StepResult
+	for idx, step := range t.Steps {
+		if started[idx] || step.Action == nil {
+			continue
+		}
+		sr := &StepResult{Index: idx + 1, ID: step.ID, Name: step.Name, Type: step.Type}
+		sr.Status = operators.StatusSkipped
+		sr.Reason = "not started as the run stopped after a failed step"
+		if f := find(idx); f >= 0 {
+			sr.Reason = fmt.Sprintf("dependency failed: step [%d]", f+1)
+		}
+		log.Ctx(stepContext(ctx, idx, step)).Info().Str("reason", sr.Reason).Msg("step skipped")
+		results = append(results, sr)
+	}
+	return results
+}
+
+// runHandlers runs each notified handler once, in the order the handlers are defined, after the steps completed.
+// Handlers changing something may notify the handlers defined after them. The failed handler is returned when a
+// handler fails without ignoring errors, the remaining handlers are not run.
+func runHandlers(ctx context.Context, t *config.TemplateData, notified map[string]bool) ([]*StepResult, *StepResult) {
+	var results []*StepResult
+	for idx, h := range t.Handlers {
+		if !notified[h.Name] || h.Action == nil {
+			continue
+		}
+		hctx := log.Ctx(ctx).With().Str("handler", h.Name).Str("type", h.Type).Logger().WithContext(ctx)
+		log.Ctx(hctx).Info().Msg("running notified handler")
+		// handlers are numbered after the last step so their results never share an index with a step
+		sr := executeStep(hctx, len(t.Steps)+idx, h)
+		sr.Handler = true
+		results = append(results, sr)
+		if sr.Err != nil && !sr.Ignored {
+			log.Ctx(hctx).Error().Err(sr.Err).Msg("error executing handler")
+			return results, sr
+		}
+		if sr.Status == operators.StatusChanged {
+			changes.FromContext(ctx).RecordHandler(h.Name, sr.Changes()...)
+			for _, n := range h.Notify {
+				notified[n] = true
+			}
+		}
+	}
+	return results, nil
+}
+
+// notifiedHandlers returns the sorted names of the notified handlers.
+func notifiedHandlers(notified map[string]bool) []string {
+	names := make([]string, 0, len(notified))
+	for n := range notified {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// defaultBackupKeep is the number of runs with backups kept when the manifest does not set backupKeep.
+const defaultBackupKeep = 10
+
+func backupDir(t *config.TemplateData) string {
+	if len(t.BackupDir) > 0 {
+		return t.BackupDir
+	}
+	return backup.DefaultDir()
+}
+
+// pruneBackups applies the backup retention of the manifest once a run completes.
+func pruneBackups(t *config.TemplateData) {
+	keep := t.BackupKeep
+	if keep == 0 {
+		keep = defaultBackupKeep
+	}
+	if err := backup.Prune(backupDir(t), keep, t.BackupMaxAge); err != nil {
+		log.Warn().Err(err).Msg("could not prune old backups")
+	}
+}
+
+// rollback restores the files changed during the run, returning the restored paths.
+func rollback(ctx context.Context) []string {
+	log.Ctx(ctx).Warn().Msg("rolling back files changed during this run")
+	restored, err := backup.Rollback()
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("rollback did not complete")
+	}
+	log.Ctx(ctx).Info().Msgf("rollback restored %d file(s)", len(restored))
+	return restored
+}
+
+// executeStep runs the step action and records the result along with the duration of the execution.
+// Failed executions are retried with an exponential backoff and each attempt is bound by the step timeout.
+func executeStep(ctx context.Context, idx int, step config.Steps) *StepResult {
+	sr := &StepResult{Index: idx + 1, ID: step.ID, Name: step.Name, Type: step.Type}
+	start := time.Now()
+	if len(step.Defaults) > 0 || len(step.With) > 0 {
+		ctx = roleScope(ctx, step.Defaults, step.With)
+	}
+	if len(step.Env) > 0 {
+		ctx = stepScope(ctx, step.Env)
+	}
+	delay := step.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	var res *operators.Result
+	var err error
+	for {
+		sr.Attempts++
+		res, err = executeAttempt(ctx, step)
+		if err == nil || sr.Attempts > step.Retries || ctx.Err() != nil {
+			break
+		}
+		log.Ctx(ctx).Warn().Err(err).Int("attempt", sr.Attempts).Int("maxAttempts", step.Retries+1).Dur("retryIn", delay).Msg("step failed, retrying")
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	if res != nil {
+		sr.Result = *res
+	}
+	sr.Duration = time.Since(start)
+	if err != nil {
+		sr.Status = operators.StatusFailed
+		sr.Err = err
+		sr.Reason = err.Error()
+		if step.IgnoreErrors {
+			sr.Ignored = true
+			log.Ctx(ctx).Warn().Err(err).Msg("step failed, continuing as errors are ignored")
+		}
+	} else if sr.Status == "" {
+		sr.Status = operators.StatusOk
+	}
+	log.Ctx(ctx).Debug().Str("status", string(sr.Status)).Int("attempts", sr.Attempts).Dur("duration", sr.Duration).Msg(sr.Reason)
+	return sr
+}
+
+// stepContext returns a context with a logger carrying the step index, id, name and operator type as fields.
+func stepContext(ctx context.Context, idx int, step config.Steps) context.Context {
+	lc := log.Ctx(ctx).With().Int("step", idx+1).Str("type", step.Type)
+	if len(step.ID) > 0 {
+		lc = lc.Str("id", step.ID)
+	}
+	if len(step.Name) > 0 {
+		lc = lc.Str("name", step.Name)
+	}
+	return lc.Logger().WithContext(ctx)
+}
+
+// stepScope returns a context with the env vars of a step layered over the run variables, the values may refer to
+// the run variables.
+func stepScope(ctx context.Context, env map[string]string) context.Context {
+	values := make(map[string]string, len(env))
+	for k, v := range env {
+		values[k] = operators.RenderEnvString(ctx, v)
+	}
+	return vars.WithScope(ctx, vars.FromContext(ctx).Child(values))
+}
+
+// roleScope returns a context with the variables of the role defining a step, the role defaults only apply to the
+// variables the run does not set while the role parameters take precedence. Values may refer to the run variables.
+func roleScope(ctx context.Context, defaults, with map[string]string) context.Context {
+	scope := vars.FromContext(ctx)
+	values := make(map[string]string, len(defaults)+len(with))
+	for k, v := range defaults {
+		if _, ok := scope.Lookup(k); !ok {
+			values[k] = operators.RenderEnvString(ctx, v)
+		}
+	}
+	for k, v := range with {
+		values[k] = operators.RenderEnvString(ctx, v)
+	}
+	return vars.WithScope(ctx, scope.Child(values))
+}
+
+// executeAttempt runs the step action once, cancelling it when the step timeout is reached.
+func executeAttempt(ctx context.Context, step config.Steps) (*operators.Result, error) {
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, step.Timeout)
+		defer cancel()
+	}
+	res, err := step.Action.Execute(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s: %w", step.Timeout, err)
+	}
+	return res, err
+}
+
+// logSummary logs the number of steps for each status once a run completes.
+func logSummary(ctx context.Context, results []*StepResult) {
+	counts := make(map[operators.Status]int)
+	ignored := 0
+	for _, r := range results {
+		counts[r.Status]++
+		if r.Ignored {
+			ignored++
+		}
+	}
+	log.Ctx(ctx).Info().Msgf("run complete: %d changed, %d ok, %d skipped, %d failed (%d ignored)", counts[operators.StatusChanged], counts[operators.StatusOk], counts[operators.StatusSkipped], counts[operators.StatusFailed], ignored)
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"bruce/changes"
+	"bruce/config"
+	"bruce/operators"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyOperator fails until it has been executed succeedOn times, a zero succeedOn always fails.
+type flakyOperator struct {
+	succeedOn int
+	calls     int
+	sleep     time.Duration
+}
+
+func (f *flakyOperator) Execute(ctx context.Context) (*operators.Result, error) {
+	f.calls++
+	if f.sleep > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(f.sleep):
+		}
+	}
+	if f.succeedOn > 0 && f.calls >= f.succeedOn {
+		return operators.Changed("succeeded"), nil
+	}
+	return nil, fmt.Errorf("attempt %d failed", f.calls)
+}
+
+func TestExecuteStep(t *testing.T) {
+	tests := []struct {
+		name         string
+		step         config.Steps
+		op           *flakyOperator
+		wantStatus   operators.Status
+		wantAttempts int
+		wantIgnored  bool
+	}{
+		{
+			name:         "no retries",
+			step:         config.Steps{},
+			op:           &flakyOperator{},
+			wantStatus:   operators.StatusFailed,
+			wantAttempts: 1,
+		},
+		{
+			name:         "succeeds on retry",
+			step:         config.Steps{Retries: 3, RetryDelay: time.Millisecond},
+			op:           &flakyOperator{succeedOn: 2},
+			wantStatus:   operators.StatusChanged,
+			wantAttempts: 2,
+		},
+		{
+			name:         "retries exhausted and ignored",
+			step:         config.Steps{Retries: 2, RetryDelay: time.Millisecond, IgnoreErrors: true},
+			op:           &flakyOperator{},
+			wantStatus:   operators.StatusFailed,
+			wantAttempts: 3,
+			wantIgnored:  true,
+		},
+		{
+			name:         "timeout",
+			step:         config.Steps{Timeout: 10 * time.Millisecond},
+			op:           &flakyOperator{succeedOn: 1, sleep: time.Second},
+			wantStatus:   operators.StatusFailed,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.step.Action = tt.op
+			sr := executeStep(context.Background(), 0, tt.step)
+			if sr.Status != tt.wantStatus {
+				t.Errorf("executeStep() status = %s, want %s", sr.Status, tt.wantStatus)
+			}
+			if sr.Attempts != tt.wantAttempts || tt.op.calls != tt.wantAttempts {
+				t.Errorf("executeStep() attempts = %d (calls %d), want %d", sr.Attempts, tt.op.calls, tt.wantAttempts)
+			}
+			if sr.Ignored != tt.wantIgnored {
+				t.Errorf("executeStep() ignored = %t, want %t", sr.Ignored, tt.wantIgnored)
+			}
+		})
+	}
+}
+
+func TestRunStepsIgnoreErrors(t *testing.T) {
+	last := &flakyOperator{succeedOn: 1}
+	td := &config.TemplateData{BackupDir: t.TempDir(), Steps: config.StepList{
+		{Action: &flakyOperator{}, IgnoreErrors: true},
+		{Action: last},
+	}}
+	results, err := runSteps(context.Background(), td, "test", nil, StepFilter{})
+	if err != nil {
+		t.Fatalf("runSteps() error = %v", err)
+	}
+	if len(results) != 2 || last.calls != 1 {
+		t.Errorf("runSteps() ran %d steps, want 2", len(results))
+	}
+
+	td.Steps[0].IgnoreErrors = false
+	last.calls = 0
+	results, err = runSteps(context.Background(), td, "test", nil, StepFilter{})
+	if err == nil || last.calls != 0 {
+		t.Errorf("runSteps() should stop at the first failed step, err = %v", err)
+	}
+	if len(results) != 2 || results[1].Status != operators.StatusSkipped {
+		t.Errorf("runSteps() should report the steps after the failed step as skipped")
+	}
+}
+
+// barrierOperator only succeeds when all of the operators sharing its wait group run at the same time.
+type barrierOperator struct {
+	wg *sync.WaitGroup
+}
+
+func (b *barrierOperator) Execute(ctx context.Context) (*operators.Result, error) {
+	b.wg.Done()
+	waited := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		return operators.Changed("ran in parallel"), nil
+	case <-time.After(2 * time.Second):
+		return nil, fmt.Errorf("steps did not run in parallel")
+	}
+}
+
+func TestRunStepsParallel(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	last := &flakyOperator{succeedOn: 1}
+	td := &config.TemplateData{BackupDir: t.TempDir(), Workers: 2, Steps: config.StepList{
+		{ID: "a", Action: &barrierOperator{wg: wg}},
+		{ID: "b", Action: &barrierOperator{wg: wg}, DependsOn: []string{}},
+		{ID: "c", Action: last, DependsOn: []string{"a", "b"}},
+	}}
+	results, err := runSteps(context.Background(), td, "test", nil, StepFilter{})
+	if err != nil {
+		t.Fatalf("runSteps() error = %v", err)
+	}
+	if len(results) != 3 || last.calls != 1 {
+		t.Fatalf("runSteps() ran %d steps, want 3", len(results))
+	}
+	for i, r := range results {
+		if r.Index != i+1 || r.ID != td.Steps[i].ID {
+			t.Errorf("runSteps() result %d = step [%d] %s, want results in step order", i, r.Index, r.ID)
+		}
+	}
+}
+
+func TestRunStepsParallelFailure(t *testing.T) {
+	independent := &flakyOperator{succeedOn: 1, sleep: 20 * time.Millisecond}
+	dependent := &flakyOperator{succeedOn: 1}
+	td := &config.TemplateData{BackupDir: t.TempDir(), Workers: 2, Steps: config.StepList{
+		{ID: "fails", Action: &flakyOperator{}},
+		{ID: "independent", Action: independent, DependsOn: []string{}},
+		{ID: "dependent", Action: dependent, DependsOn: []string{"fails"}},
+	}}
+	results, err := runSteps(context.Background(), td, "test", nil, StepFilter{})
+	if err == nil {
+		t.Fatalf("runSteps() should fail when a step fails")
+	}
+	if independent.calls != 1 {
+		t.Errorf("runSteps() should let the running steps complete")
+	}
+	if dependent.calls != 0 {
+		t.Errorf("runSteps() started a step depending on a failed step")
+	}
+	if len(results) != 3 {
+		t.Fatalf("runSteps() = %d results, want every step reported", len(results))
+	}
+	if r := results[2]; r.ID != "dependent" || r.Status != operators.StatusSkipped || r.Reason != "dependency failed: step [1]" {
+		t.Errorf("runSteps() blocked step = %s %s %q, want skipped as its dependency failed", r.ID, r.Status, r.Reason)
+	}
+}
+
+// countingOperator reports a change (or no change) and counts its executions.
+type countingOperator struct {
+	changed bool
+	calls   int
+}
+
+func (c *countingOperator) Execute(ctx context.Context) (*operators.Result, error) {
+	c.calls++
+	if c.changed {
+		return operators.Changed("changed"), nil
+	}
+	return operators.Ok("unchanged"), nil
+}
+
+func TestRunStepsHandlers(t *testing.T) {
+	reload := &countingOperator{changed: true}
+	restart := &countingOperator{}
+	cleanup := &countingOperator{}
+	td := &config.TemplateData{BackupDir: t.TempDir(),
+		Steps: config.StepList{
+			{Action: &countingOperator{changed: true}, Notify: config.StringList{"reload nginx"}},
+			{Action: &countingOperator{changed: true}, Notify: config.StringList{"reload nginx"}},
+			{Action: &countingOperator{}, Notify: config.StringList{"restart app"}},
+		},
+		Handlers: config.StepList{
+			{Name: "reload nginx", Action: reload, Notify: config.StringList{"cleanup"}},
+			{Name: "restart app", Action: restart},
+			{Name: "cleanup", Action: cleanup},
+		},
+	}
+	results, err := runSteps(context.Background(), td, "test", nil, StepFilter{})
+	if err != nil {
+		t.Fatalf("runSteps() error = %v", err)
+	}
+	if reload.calls != 1 {
+		t.Errorf("runSteps() ran the notified handler %d times, want once", reload.calls)
+	}
+	if restart.calls != 0 {
+		t.Errorf("runSteps() ran a handler notified by an unchanged step")
+	}
+	if cleanup.calls != 1 {
+		t.Errorf("runSteps() did not run the handler notified by a handler")
+	}
+	if len(results) != 5 || !results[3].Handler || results[3].Name != "reload nginx" {
+		t.Errorf("runSteps() = %d results, want the 3 steps followed by the 2 handlers", len(results))
+	}
+	seen := make(map[int]bool)
+	for _, r := range results {
+		if seen[r.Index] || (r.Handler && r.Index <= len(td.Steps)) {
+			t.Errorf("runSteps() %s has index %d, want handlers numbered after the steps", r.Name, r.Index)
+		}
+		seen[r.Index] = true
+	}
+
+	td.Steps[2].Action = &flakyOperator{}
+	reload.calls = 0
+	if _, err := runSteps(context.Background(), td, "test", nil, StepFilter{}); err == nil || reload.calls != 0 {
+		t.Errorf("runSteps() should not run handlers when a step failed, err = %v", err)
+	}
+}
+
+// changeOperator changes the resources it is given, or reports whether they were changed by a previous step.
+type changeOperator struct {
+	resources []string
+	seen      bool
+}
+
+func (c *changeOperator) Execute(ctx context.Context) (*operators.Result, error) {
+	if len(c.resources) > 0 {
+		return operators.Changed("changed").WithFiles(c.resources[0]).WithResources("package", c.resources[1:]...), nil
+	}
+	c.seen = changes.FromContext(ctx).Changed("/etc/app.conf") && changes.FromContext(ctx).Changed("package:app")
+	return operators.Ok("checked"), nil
+}
+
+func TestRunStepsChanges(t *testing.T) {
+	check := &changeOperator{}
+	td := &config.TemplateData{BackupDir: t.TempDir(), Workers: 2,
+		Steps: config.StepList{
+			{ID: "config", Action: &changeOperator{resources: []string{"/etc/app.conf", "app"}}},
+			{Action: check, DependsOn: []string{"config"}},
+		},
+	}
+	results, err := runSteps(context.Background(), td, "test", nil, StepFilter{})
+	if err != nil {
+		t.Fatalf("runSteps() error = %v", err)
+	}
+	if !check.seen {
+		t.Errorf("runSteps() did not record the changes of a step before its dependents ran")
+	}
+	if got := results[0].Changes(); len(got) != 2 || got[1] != "package:app" {
+		t.Errorf("Changes() = %v, want the file and package", got)
+	}
+
+	check.seen = false
+	next := &config.TemplateData{BackupDir: td.BackupDir, Steps: config.StepList{{Action: check}}}
+	if _, err := runSteps(context.Background(), next, "test", nil, StepFilter{}); err != nil || check.seen {
+		t.Errorf("runSteps() should not share changes between runs, err = %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bruce/loader"
 	"bruce/mutation"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/minio/selfupdate"
@@ -42,7 +43,7 @@ func Upgrade(currentVersion string) error {
 	if runtime.GOOS == "windows" {
 		fName += ".exe"
 	}
-	err = mutation.ExtractTarball(url, updateDir, true, true)
+	err = mutation.ExtractTarball(context.Background(), url, updateDir, true, true)
 	if err != nil {
 		log.Fatalf("Error downloading tarball: %s", err)
 	}
@@ -3,6 +3,7 @@ package loader
 import (
 	"bruce/exe"
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"io"
@@ -16,11 +17,16 @@ type PageLink struct {
 }
 
 func CopyFile(src, dest string, perm os.FileMode, overwrite bool) error {
+	return CopyFileContext(context.Background(), src, dest, perm, overwrite)
+}
+
+// CopyFileContext is CopyFile where http and s3 reads are cancelled along with ctx.
+func CopyFileContext(ctx context.Context, src, dest string, perm os.FileMode, overwrite bool) error {
 	// if filemode is 0, set it to 0644
 	if perm == 0 {
 		perm = 0644
 	}
-	sd, _, err := GetRemoteData(src)
+	sd, _, err := GetRemoteDataContext(ctx, src)
 	if err != nil {
 		log.Error().Err(err).Msg("cannot open source file")
 		return err
@@ -68,13 +74,19 @@ func CopyFile(src, dest string, perm os.FileMode, overwrite bool) error {
 }
 
 func RecursiveCopy(src string, baseDir, dest string, overwrite bool, ignores []string, isFlatCopy bool, maxDepth, maxConcurrent int) error {
+	return RecursiveCopyContext(context.Background(), src, baseDir, dest, overwrite, ignores, isFlatCopy, maxDepth, maxConcurrent)
+}
+
+// RecursiveCopyContext is RecursiveCopy where no new files are copied once ctx is done, the error of ctx is returned
+// when the copy did not complete.
+func RecursiveCopyContext(ctx context.Context, src string, baseDir, dest string, overwrite bool, ignores []string, isFlatCopy bool, maxDepth, maxConcurrent int) error {
 	if src[0:4] == "http" {
 		// This is a remote http copy
-		return recursiveHttpCopy(src, baseDir, dest, overwrite, ignores, isFlatCopy, maxDepth, maxConcurrent)
+		return recursiveHttpCopy(ctx, src, baseDir, dest, overwrite, ignores, isFlatCopy, maxDepth, maxConcurrent)
 	}
 	if src[0:5] == "s3://" {
 		// This is a remote s3 copy
-		return recursiveS3Copy(src, baseDir, dest, overwrite, ignores, isFlatCopy, maxDepth, maxConcurrent)
+		return recursiveS3Copy(ctx, src, baseDir, dest, overwrite, ignores, isFlatCopy, maxDepth, maxConcurrent)
 	}
 	return recursiveNotSupported(src, baseDir, dest, overwrite, ignores, isFlatCopy, maxDepth)
 }
@@ -1,6 +1,7 @@
 package loader
 
 import (
+	"context"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"io"
@@ -13,7 +14,12 @@ import (
 )
 
 func ReadFromHttp(fileName string) ([]byte, string, error) {
-	req, err := http.NewRequest("GET", fileName, nil)
+	return ReadFromHttpContext(context.Background(), fileName)
+}
+
+// ReadFromHttpContext is ReadFromHttp with the request bound to ctx.
+func ReadFromHttpContext(ctx context.Context, fileName string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fileName, nil)
 	if err != nil {
 		return nil, "", err
 	}
@@ -33,9 +39,36 @@ func ReadFromHttp(fileName string) ([]byte, string, error) {
 	return d, fn, nil
 }
 
+// ReaderFromHttpContext streams the body of a http file, the request is bound to ctx.
+func ReaderFromHttpContext(ctx context.Context, fileName string) (io.ReadCloser, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fileName, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	fn := path.Base(resp.Request.URL.String())
+	if resp.StatusCode >= 400 {
+		log.Error().Err(resp.Body.Close())
+		return nil, fn, fmt.Errorf("could not read http file %s: %s", fileName, resp.Status)
+	}
+	return resp.Body, fn, nil
+}
+
 func ReadRemoteHttpIndex(remoteLoc string) ([]PageLink, error) {
+	return ReadRemoteHttpIndexContext(context.Background(), remoteLoc)
+}
+
+// ReadRemoteHttpIndexContext is ReadRemoteHttpIndex with the request bound to ctx.
+func ReadRemoteHttpIndexContext(ctx context.Context, remoteLoc string) ([]PageLink, error) {
 	log.Debug().Msgf("reading remote http index: %s", remoteLoc)
-	resp, err := http.Get(remoteLoc)
+	req, err := http.NewRequestWithContext(ctx, "GET", remoteLoc, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -66,14 +99,14 @@ func ReadRemoteHttpIndex(remoteLoc string) ([]PageLink, error) {
 }
 
 func GetHttpRecursiveList(src string, maxDepth int) ([]string, error) {
-	return getHttpRecursiveListWithDepth(src, maxDepth, 0)
+	return getHttpRecursiveListWithDepth(context.Background(), src, maxDepth, 0)
 }
 
-func getHttpRecursiveListWithDepth(src string, maxDepth, currentDepth int) ([]string, error) {
+func getHttpRecursiveListWithDepth(ctx context.Context, src string, maxDepth, currentDepth int) ([]string, error) {
 	var files []string
 	if strings.HasSuffix(src, "/") {
 		log.Debug().Msgf("List remote http index: %s", src)
-		links, err := ReadRemoteHttpIndex(src)
+		links, err := ReadRemoteHttpIndexContext(ctx, src)
 		if err != nil {
 			log.Debug().Err(err).Msg("could not read remote http index")
 			return nil, err
@@ -92,7 +125,7 @@ func getHttpRecursiveListWithDepth(src string, maxDepth, currentDepth int) ([]st
 					return nil, err
 				}
 				if maxDepth == 0 || currentDepth < maxDepth {
-					dfiles, err := getHttpRecursiveListWithDepth(nurl, maxDepth, currentDepth+1)
+					dfiles, err := getHttpRecursiveListWithDepth(ctx, nurl, maxDepth, currentDepth+1)
 					if err != nil {
 						return nil, err
 					}
@@ -125,23 +158,27 @@ func joinURL(baseURL, path string) (string, error) {
 	return joinedURL.String(), nil
 }
 
-func downloadFile(src, dest string, overwrite bool, wg *sync.WaitGroup, semaphore chan struct{}) {
+func downloadFile(ctx context.Context, src, dest string, overwrite bool, wg *sync.WaitGroup, semaphore chan struct{}) {
 	defer wg.Done()
 
-	semaphore <- struct{}{}
-	err := CopyFile(src, dest, 0664, overwrite)
+	select {
+	case semaphore <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	err := CopyFileContext(ctx, src, dest, 0664, overwrite)
 	if err != nil {
 		log.Error().Err(err).Msg("could not copy file")
 	}
 	<-semaphore
 }
 
-func recursiveHttpCopy(src string, baseDir, dest string, overwrite bool, ignores []string, isFlatCopy bool, maxDepth, maxConcurrent int) error {
+func recursiveHttpCopy(ctx context.Context, src string, baseDir, dest string, overwrite bool, ignores []string, isFlatCopy bool, maxDepth, maxConcurrent int) error {
 	if dest == "" {
 		dest = baseDir
 	}
 	log.Debug().Str("src", src).Str("dest", dest).Msg("recursively copying")
-	list, err := GetHttpRecursiveList(src, maxDepth)
+	list, err := getHttpRecursiveListWithDepth(ctx, src, maxDepth, 0)
 	if err != nil {
 		return err
 	}
@@ -149,6 +186,10 @@ func recursiveHttpCopy(src string, baseDir, dest string, overwrite bool, ignores
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, maxConcurrent)
 	for _, file := range list {
+		// stop starting downloads once the copy is cancelled
+		if ctx.Err() != nil {
+			break
+		}
 		lFile := strings.Replace(file, src, "", 1)
 		aDest := isFlatCopyDest(lFile, baseDir, dest, isFlatCopy)
 
@@ -165,9 +206,9 @@ func recursiveHttpCopy(src string, baseDir, dest string, overwrite bool, ignores
 		}
 
 		wg.Add(1)
-		go downloadFile(file, aDest, overwrite, &wg, semaphore)
+		go downloadFile(ctx, file, aDest, overwrite, &wg, semaphore)
 	}
 
 	wg.Wait()
-	return nil
+	return ctx.Err()
 }
@@ -1,6 +1,8 @@
 package loader
 
 import (
+	"context"
+	"io"
 	"strings"
 )
 
@@ -14,12 +16,29 @@ func ReadRemoteFile(remoteLoc string) ([]byte, string, error) {
 
 // GetRemoteData returns a ReadCloser with a filename and error if exists.
 func GetRemoteData(remoteLoc string) ([]byte, string, error) {
+	return GetRemoteDataContext(context.Background(), remoteLoc)
+}
+
+// GetRemoteDataContext is GetRemoteData where http and s3 reads are cancelled along with ctx.
+func GetRemoteDataContext(ctx context.Context, remoteLoc string) ([]byte, string, error) {
 	if strings.ToLower(remoteLoc[0:4]) == "http" {
-		return ReadFromHttp(remoteLoc)
+		return ReadFromHttpContext(ctx, remoteLoc)
 	}
 	if strings.ToLower(remoteLoc[0:5]) == "s3://" {
-		return ReadFromS3(remoteLoc)
+		return ReadFromS3Context(ctx, remoteLoc)
 	}
 	// if no remote handlers can handle the reading of the file, lets try local
 	return ReadFromLocal(remoteLoc)
 }
+
+// GetRemoteReaderContext is GetRemoteDataContext returning a reader so large files are streamed instead of read into
+// memory, the caller must close the reader.
+func GetRemoteReaderContext(ctx context.Context, remoteLoc string) (io.ReadCloser, string, error) {
+	if strings.ToLower(remoteLoc[0:4]) == "http" {
+		return ReaderFromHttpContext(ctx, remoteLoc)
+	}
+	if strings.ToLower(remoteLoc[0:5]) == "s3://" {
+		return ReaderFromS3Context(ctx, remoteLoc)
+	}
+	return ReaderFromLocal(remoteLoc)
+}
@@ -29,6 +29,18 @@ func ReadFromLocal(fileName string) ([]byte, string, error) {
 	return d, fn, err
 }
 
+// ReaderFromLocal opens a local file for streaming.
+func ReaderFromLocal(fileName string) (io.ReadCloser, string, error) {
+	fn := path.Base(fileName)
+	log.Debug().Msgf("starting local read of %s", fileName)
+	f, err := os.Open(fileName)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not open file for reading: %s", fileName)
+		return nil, fn, err
+	}
+	return f, fn, nil
+}
+
 func WriterFromLocal(fileName string) (io.WriteCloser, error) {
 	w, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0664)
 	if err != nil {
@@ -28,6 +28,26 @@ type S3Manager struct {
 }
 
 func ReadFromS3(fileName string) ([]byte, string, error) {
+	return ReadFromS3Context(context.Background(), fileName)
+}
+
+// ReadFromS3Context is ReadFromS3 with the object request bound to ctx.
+func ReadFromS3Context(ctx context.Context, fileName string) ([]byte, string, error) {
+	rc, fn, err := ReaderFromS3Context(ctx, fileName)
+	if err != nil {
+		return nil, fn, err
+	}
+	d, err := io.ReadAll(rc)
+	if err != nil {
+		log.Debug().Err(err).Msg("reading object from S3 failed")
+		return nil, fn, err
+	}
+	log.Error().Err(rc.Close())
+	return d, fn, nil
+}
+
+// ReaderFromS3Context streams the body of an S3 object, the request is bound to ctx.
+func ReaderFromS3Context(ctx context.Context, fileName string) (io.ReadCloser, string, error) {
 	fn := path.Base(fileName)
 	if s == nil {
 		region := os.Getenv("AWS_REGION")
@@ -42,14 +62,11 @@ func ReadFromS3(fileName string) ([]byte, string, error) {
 		}
 		s.Service = s3.New(sess)
 	}
-	ctx := context.Background()
-	var cancelFn func()
+	cancelFn := func() {}
 	if s.Timeout > 0 {
+		// the timeout covers reading the body so the context is only canceled once the reader is closed
 		ctx, cancelFn = context.WithTimeout(ctx, s.Timeout)
 	}
-	// Ensure the context is canceled to prevent leaking.
-	// See context package for more information, https://golang.org/pkg/context/
-	defer cancelFn()
 	pfxCut := fileName[5:]
 	subIdx := strings.Index(pfxCut, "/")
 	bucket := pfxCut[:subIdx]
@@ -59,22 +76,32 @@ func ReadFromS3(fileName string) ([]byte, string, error) {
 		Key:    aws.String(objKey),
 	})
 	if err != nil {
+		cancelFn()
 		log.Debug().Err(err).Msg("fetching object from S3 failed")
 		return nil, fn, err
 	}
-	d, err := io.ReadAll(fd.Body)
-	if err != nil {
-		log.Debug().Err(err).Msg("reading object from S3 failed")
-		return nil, fn, err
-	}
-	log.Error().Err(fd.Body.Close())
-	return d, fn, nil
+	return &s3Body{ReadCloser: fd.Body, cancel: cancelFn}, fn, nil
+}
+
+// s3Body cancels the request context of an object once its body is closed.
+type s3Body struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (b *s3Body) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
 }
 
-func downloadS3File(svc *s3.S3, bucket, key, aDest string, overwrite bool, wg *sync.WaitGroup, semaphore chan struct{}) {
+func downloadS3File(ctx context.Context, svc *s3.S3, bucket, key, aDest string, overwrite bool, wg *sync.WaitGroup, semaphore chan struct{}) {
 	defer wg.Done()
 
-	semaphore <- struct{}{}
+	select {
+	case semaphore <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
 
 	destPath := aDest
 	if !overwrite {
@@ -102,7 +129,7 @@ func downloadS3File(svc *s3.S3, bucket, key, aDest string, overwrite bool, wg *s
 	defer file.Close()
 
 	downloader := s3manager.NewDownloaderWithClient(svc)
-	_, err = downloader.Download(file, &s3.GetObjectInput{
+	_, err = downloader.DownloadWithContext(ctx, file, &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
@@ -113,7 +140,7 @@ func downloadS3File(svc *s3.S3, bucket, key, aDest string, overwrite bool, wg *s
 	<-semaphore
 }
 
-func recursiveS3Copy(src string, baseDir, dest string, overwrite bool, ignores []string, isFlatCopy bool, maxDepth, maxConcurrent int) error {
+func recursiveS3Copy(ctx context.Context, src string, baseDir, dest string, overwrite bool, ignores []string, isFlatCopy bool, maxDepth, maxConcurrent int) error {
 	parsedURL, err := url.Parse(src)
 	if err != nil {
 		return fmt.Errorf("invalid S3 URL: %v", err)
@@ -145,6 +172,10 @@ func recursiveS3Copy(src string, baseDir, dest string, overwrite bool, ignores [
 	var downloadFunc func(page *s3.ListObjectsV2Output, lastPage bool) bool
 	downloadFunc = func(page *s3.ListObjectsV2Output, lastPage bool) bool {
 		for _, obj := range page.Contents {
+			// stop starting downloads once the copy is cancelled
+			if ctx.Err() != nil {
+				break
+			}
 			objKey := *obj.Key
 			aDest := isFlatCopyDest(objKey, baseDir, dest, isFlatCopy)
 
@@ -161,12 +192,12 @@ func recursiveS3Copy(src string, baseDir, dest string, overwrite bool, ignores [
 			}
 
 			wg.Add(1)
-			go downloadS3File(svc, bucket, objKey, aDest, overwrite, &wg, semaphore)
+			go downloadS3File(ctx, svc, bucket, objKey, aDest, overwrite, &wg, semaphore)
 		}
 
 		wg.Wait()
 
-		if maxDepth <= 0 {
+		if maxDepth <= 0 || ctx.Err() != nil {
 			return false
 		}
 		if *page.IsTruncated {
@@ -175,7 +206,7 @@ func recursiveS3Copy(src string, baseDir, dest string, overwrite bool, ignores [
 				Prefix:            aws.String(prefix),
 				ContinuationToken: page.NextContinuationToken,
 			}
-			err := svc.ListObjectsV2PagesWithContext(context.Background(), input, downloadFunc)
+			err := svc.ListObjectsV2PagesWithContext(ctx, input, downloadFunc)
 			if err != nil {
 				log.Printf("error listing objects: %v", err)
 			}
@@ -186,12 +217,11 @@ func recursiveS3Copy(src string, baseDir, dest string, overwrite bool, ignores [
 		Bucket: aws.String(bucket),
 		Prefix: aws.String(prefix),
 	}
-	ctx := context.Background()
 	err = svc.ListObjectsV2PagesWithContext(ctx, input, downloadFunc)
 	if err != nil {
 		log.Printf("error listing objects: %v", err)
 	}
-	return nil
+	return ctx.Err()
 }
 func isFlatCopyDest(filename, baseDir, dest string, isFlatCopy bool) string {
 	if isFlatCopy {
@@ -0,0 +1,170 @@
+package lock
+
+import (
+	"bruce/system"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Modes control what happens when another run already holds the lock.
+const (
+	ModeWait = "wait"
+	ModeSkip = "skip"
+	ModeFail = "fail"
+)
+
+// ErrLocked is returned when the lock is held by another run.
+var ErrLocked = errors.New("another bruce run holds the lock")
+
+// Holder describes the run holding the lock, it is written to the lock file while the lock is held.
+type Holder struct {
+	PID     int       `json:"pid"`
+	Action  string    `json:"action"`
+	Source  string    `json:"source"`
+	Started time.Time `json:"started"`
+}
+
+// Lock is a host wide lock held by a single run.
+type Lock struct {
+	f *os.File
+}
+
+// Path returns the lock file location, /var/run/bruce.lock for root or the state directory for other users.
+func Path() string {
+	if os.Geteuid() == 0 {
+		return "/var/run/bruce.lock"
+	}
+	return filepath.Join(system.StateDir(), "bruce.lock")
+}
+
+// ValidMode returns an error for unknown lock modes, an empty mode is the same as wait.
+func ValidMode(mode string) error {
+	switch mode {
+	case "", ModeWait, ModeSkip, ModeFail:
+		return nil
+	}
+	return fmt.Errorf("unknown lock mode: %s (must be wait, skip or fail)", mode)
+}
+
+// Acquire takes the lock at path for the holder. When the lock is held by another run, wait mode polls until the
+// lock is free or ctx is done while skip and fail return ErrLocked immediately.
+func Acquire(ctx context.Context, path, mode string, h Holder) (*Lock, error) {
+	if err := ValidMode(mode); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	waiting := false
+	for {
+		err = tryLock(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrLocked) || (mode != "" && mode != ModeWait) {
+			f.Close()
+			return nil, err
+		}
+		if !waiting {
+			if cur, rerr := readHolder(f); rerr == nil && cur != nil {
+				log.Info().Msgf("waiting for %s (pid %d) to finish", cur.Action, cur.PID)
+			}
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+	l := &Lock{f: f}
+	if err := l.write(&h); err != nil {
+		l.Release()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Lock) write(h *Holder) error {
+	if err := l.f.Truncate(0); err != nil {
+		return err
+	}
+	if h == nil {
+		return nil
+	}
+	d, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	_, err = l.f.WriteAt(d, 0)
+	return err
+}
+
+// Release clears the holder and releases the lock.
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	werr := l.write(nil)
+	uerr := unlock(l.f)
+	cerr := l.f.Close()
+	l.f = nil
+	return errors.Join(werr, uerr, cerr)
+}
+
+// Status returns the holder of the lock at path, or nil when no run holds it.
+func Status(path string) (*Holder, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	err = tryLock(f)
+	if err == nil {
+		unlock(f)
+		return nil, nil
+	}
+	if !errors.Is(err, ErrLocked) {
+		return nil, err
+	}
+	h, err := readHolder(f)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		// the holder has not written its details yet
+		return &Holder{}, nil
+	}
+	return h, nil
+}
+
+func readHolder(f *os.File) (*Holder, error) {
+	d, err := io.ReadAll(io.NewSectionReader(f, 0, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if len(d) == 0 {
+		return nil, nil
+	}
+	h := &Holder{}
+	if err := json.Unmarshal(d, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
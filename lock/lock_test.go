@@ -0,0 +1,56 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bruce.lock")
+	l, err := Acquire(context.Background(), path, ModeFail, Holder{PID: os.Getpid(), Action: "first"})
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	h, err := Status(path)
+	if err != nil || h == nil || h.Action != "first" || h.PID != os.Getpid() {
+		t.Fatalf("Status() = %v, %v, want holder first", h, err)
+	}
+
+	for _, mode := range []string{ModeFail, ModeSkip} {
+		if _, err := Acquire(context.Background(), path, mode, Holder{Action: "second"}); !errors.Is(err, ErrLocked) {
+			t.Errorf("Acquire(%s) error = %v, want ErrLocked", mode, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := Acquire(ctx, path, ModeWait, Holder{Action: "second"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire(wait) error = %v, want deadline exceeded", err)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if h, err := Status(path); h != nil || err != nil {
+		t.Errorf("Status() after release = %v, %v", h, err)
+	}
+	l, err = Acquire(context.Background(), path, ModeWait, Holder{Action: "second"})
+	if err != nil {
+		t.Fatalf("Acquire() after release error = %v", err)
+	}
+	l.Release()
+}
+
+func TestValidMode(t *testing.T) {
+	for _, mode := range []string{"", ModeWait, ModeSkip, ModeFail} {
+		if err := ValidMode(mode); err != nil {
+			t.Errorf("ValidMode(%q) error = %v", mode, err)
+		}
+	}
+	if err := ValidMode("block"); err == nil {
+		t.Errorf("ValidMode(block) should fail")
+	}
+}
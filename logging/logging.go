@@ -0,0 +1,101 @@
+// Package logging configures where and how bruce writes its logs.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+
+	defaultMaxSize    = 10
+	defaultMaxBackups = 5
+)
+
+// Options are the logging settings from the global flags or the log section of the server configuration.
+type Options struct {
+	Format     string `yaml:"format"`
+	File       string `yaml:"file"`
+	MaxSize    int    `yaml:"max-size"`
+	MaxBackups int    `yaml:"max-backups"`
+	Syslog     bool   `yaml:"syslog"`
+}
+
+// Merge returns o with the values set in over taking precedence.
+func (o Options) Merge(over Options) Options {
+	if len(over.Format) > 0 {
+		o.Format = over.Format
+	}
+	if len(over.File) > 0 {
+		o.File = over.File
+	}
+	if over.MaxSize > 0 {
+		o.MaxSize = over.MaxSize
+	}
+	if over.MaxBackups > 0 {
+		o.MaxBackups = over.MaxBackups
+	}
+	o.Syslog = o.Syslog || over.Syslog
+	return o
+}
+
+var closers []io.Closer
+
+// Setup replaces the global logger with one writing to stdout in the requested format, and optionally to a rotated
+// log file and the local syslog. Loggers taken from a context without one fall back to the global logger.
+func Setup(o Options) error {
+	var writers []io.Writer
+	switch o.Format {
+	case "", FormatConsole:
+		writers = append(writers, zerolog.ConsoleWriter{Out: os.Stdout})
+	case FormatJSON:
+		writers = append(writers, os.Stdout)
+	default:
+		return fmt.Errorf("unknown log format %q, must be %s or %s", o.Format, FormatConsole, FormatJSON)
+	}
+	var opened []io.Closer
+	if len(o.File) > 0 {
+		maxSize, maxBackups := o.MaxSize, o.MaxBackups
+		if maxSize <= 0 {
+			maxSize = defaultMaxSize
+		}
+		if maxBackups <= 0 {
+			maxBackups = defaultMaxBackups
+		}
+		f, err := NewRotatingFile(o.File, int64(maxSize)*1024*1024, maxBackups)
+		if err != nil {
+			return err
+		}
+		opened = append(opened, f)
+		if o.Format == FormatJSON {
+			writers = append(writers, f)
+		} else {
+			writers = append(writers, zerolog.ConsoleWriter{Out: f, NoColor: true, TimeFormat: time.RFC3339})
+		}
+	}
+	if o.Syslog {
+		w, err := syslogWriter()
+		if err != nil {
+			for _, c := range opened {
+				c.Close()
+			}
+			return fmt.Errorf("could not connect to syslog: %w", err)
+		}
+		opened = append(opened, w)
+		writers = append(writers, w)
+	}
+	log.Logger = zerolog.New(zerolog.MultiLevelWriter(writers...)).With().Timestamp().Logger()
+	zerolog.DefaultContextLogger = &log.Logger
+	for _, c := range closers {
+		c.Close()
+	}
+	closers = opened
+	return nil
+}
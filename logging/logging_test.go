@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// TestSetup checks that loggers taken from a context without one write to the configured outputs.
+func TestSetup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bruce.log")
+	if err := Setup(Options{Format: "xml"}); err == nil {
+		t.Errorf("Setup() should reject an unknown format")
+	}
+	if err := Setup(Options{Format: FormatJSON, File: path}); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	defer Setup(Options{})
+	zerolog.Ctx(context.Background()).Info().Str("runId", "abc").Msg("hello")
+	d, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(d), `"runId":"abc"`) || !strings.Contains(string(d), `"message":"hello"`) {
+		t.Errorf("log file = %s, want a json line with the runId field", d)
+	}
+}
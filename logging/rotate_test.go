@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "bruce.log")
+	r, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file string
+		want string
+	}{
+		{file: path, want: "fourth\n"},
+		{file: path + ".1", want: "third\n"},
+		{file: path + ".2", want: "second\n"},
+	}
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.file), func(t *testing.T) {
+			d, err := os.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(d) != tt.want {
+				t.Errorf("%s = %q, want %q", tt.file, d, tt.want)
+			}
+		})
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Errorf("rotation kept more than 2 backups")
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package logging
+
+import (
+	"io"
+	"log/syslog"
+
+	"github.com/rs/zerolog"
+)
+
+// syslogWriter connects to the local syslog daemon over its unix socket, the log levels map to syslog priorities.
+func syslogWriter() (io.WriteCloser, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "bruce")
+	if err != nil {
+		return nil, err
+	}
+	return &syslogLevelWriter{LevelWriter: zerolog.SyslogLevelWriter(w), w: w}, nil
+}
+
+type syslogLevelWriter struct {
+	zerolog.LevelWriter
+	w *syslog.Writer
+}
+
+func (s *syslogLevelWriter) Close() error {
+	return s.w.Close()
+}
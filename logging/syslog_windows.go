@@ -0,0 +1,12 @@
+//go:build windows
+
+package logging
+
+import (
+	"fmt"
+	"io"
+)
+
+func syslogWriter() (io.WriteCloser, error) {
+	return nil, fmt.Errorf("syslog is not supported on windows")
+}
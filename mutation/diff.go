@@ -0,0 +1,69 @@
+package mutation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffCells limits the size of the line comparison table so huge files do not exhaust memory.
+const maxDiffCells = 4_000_000
+
+// Diff returns a line based diff of two contents, lines removed are prefixed with - and lines added with +.
+// Unchanged lines are omitted, an empty string is returned when the contents are equal.
+func Diff(oldName, newName, oldContent, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+	a := splitLines(oldContent)
+	b := splitLines(newContent)
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "--- %s\n+++ %s\n", oldName, newName)
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		fmt.Fprintf(sb, "content differs (%d lines => %d lines)\n", len(a), len(b))
+		return sb.String()
+	}
+	// lcs[i][j] holds the longest common subsequence length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			fmt.Fprintf(sb, "-%s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(sb, "+%s\n", b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		fmt.Fprintf(sb, "-%s\n", a[i])
+	}
+	for ; j < len(b); j++ {
+		fmt.Fprintf(sb, "+%s\n", b[j])
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
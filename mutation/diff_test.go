@@ -0,0 +1,38 @@
+package mutation
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	type args struct {
+		oldContent string
+		newContent string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "equal",
+			args: args{oldContent: "foo\nbar\n", newContent: "foo\nbar\n"},
+			want: "",
+		},
+		{
+			name: "changed line",
+			args: args{oldContent: "foo\nbar\nbaz\n", newContent: "foo\nqux\nbaz\n"},
+			want: "--- old\n+++ new\n-bar\n+qux\n",
+		},
+		{
+			name: "new file",
+			args: args{oldContent: "", newContent: "foo\n"},
+			want: "--- old\n+++ new\n+foo\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff("old", "new", tt.args.oldContent, tt.args.newContent); got != tt.want {
+				t.Errorf("Diff() = \nrecv:%#v \nwant:%#v", got, tt.want)
+			}
+		})
+	}
+}
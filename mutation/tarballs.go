@@ -5,6 +5,7 @@ import (
 	"bruce/loader"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"io"
@@ -33,22 +34,22 @@ func sanitizePath(path string) (string, error) {
 	return cleanPath, nil
 }
 
-func ExtractTarball(src, dst string, force, stripRoot bool) error {
+func ExtractTarball(ctx context.Context, src, dst string, force, stripRoot bool) error {
 	// We just check dest currently as we will read from multiple source locations and they may fail by time we cleaned up so worthless to check upfront.
 	if _, err := os.Stat(dst); err == nil {
 		if !force {
-			log.Info().Msgf("%s already exists cannot extract tarball to location", dst)
+			log.Ctx(ctx).Info().Msgf("%s already exists cannot extract tarball to location", dst)
 			return nil
 		}
 	}
 	err := os.MkdirAll(dst, 0755)
 	if err != nil {
-		log.Error().Err(err).Msgf("cannot create directory at dst: %s", dst)
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot create directory at dst: %s", dst)
 		return err
 	}
-	rsrc, _, err := loader.GetRemoteData(src)
+	rsrc, _, err := loader.GetRemoteDataContext(ctx, src)
 	if err != nil {
-		log.Error().Err(err).Msgf("cannot read tarball at src: %s", src)
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot read tarball at src: %s", src)
 		return err
 	}
 	rr := bytes.NewReader(rsrc)
@@ -67,7 +68,7 @@ func ExtractTarball(src, dst string, force, stripRoot bool) error {
 		// the target location where the dir/file should be created
 		sanitizedPath, err := sanitizePath(header.Name)
 		if err != nil {
-			log.Error().Err(err).Msg("path traversal attempt detected, skipping entry")
+			log.Ctx(ctx).Error().Err(err).Msg("path traversal attempt detected, skipping entry")
 			continue
 		}
 		target := filepath.Join(dst, sanitizedPath)
@@ -80,7 +81,7 @@ func ExtractTarball(src, dst string, force, stripRoot bool) error {
 				isTopLevel = true
 			}
 		}
-		fmt.Printf("extracting: %s\n", target)
+		log.Ctx(ctx).Debug().Msgf("extracting: %s", target)
 		switch header.Typeflag {
 		case tar.TypeDir:
 			if !isTopLevel {
@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -160,7 +161,7 @@ func TestExtractTarball(t *testing.T) {
 	defer os.Remove("test.tar.gz")
 
 	// Test extracting tarball
-	err := ExtractTarball("test.tar.gz", "extracted", true, true)
+	err := ExtractTarball(context.Background(), "test.tar.gz", "extracted", true, true)
 	if err != nil {
 		t.Error("Expected no error, got", err)
 	}
@@ -176,7 +177,7 @@ func TestExtractTarball(t *testing.T) {
 	}
 	os.RemoveAll("extracted")
 	// Test extracting to existing directory
-	err = ExtractTarball("test.tar.gz", "extracted", false, true)
+	err = ExtractTarball(context.Background(), "test.tar.gz", "extracted", false, true)
 	if err == nil {
 		t.Error("Expected error, got nil")
 	}
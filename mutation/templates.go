@@ -2,14 +2,28 @@ package mutation
 
 import (
 	"bruce/loader"
+	"bytes"
 	"github.com/rs/zerolog/log"
 	"text/template"
 )
 
-func WriteInlineTemplate(filename, tpl string, content interface{}) error {
+// RenderInlineTemplate renders the template string with the content provided.
+func RenderInlineTemplate(tpl string, content interface{}) ([]byte, error) {
 	t, err := template.New("generator").Parse(tpl)
 	if err != nil {
 		log.Error().Err(err).Msg("could not parse cron template")
+		return nil, err
+	}
+	b := &bytes.Buffer{}
+	if err := t.Execute(b, content); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func WriteInlineTemplate(filename, tpl string, content interface{}) error {
+	d, err := RenderInlineTemplate(tpl, content)
+	if err != nil {
 		return err
 	}
 	w, err := loader.WriterFromLocal(filename)
@@ -17,5 +31,6 @@ func WriteInlineTemplate(filename, tpl string, content interface{}) error {
 		return err
 	}
 	defer w.Close()
-	return t.Execute(w, content)
+	_, err = w.Write(d)
+	return err
 }
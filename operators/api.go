@@ -1,22 +1,24 @@
 package operators
 
 import (
-	"bruce/exe"
+	"bruce/vars"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"path"
 
 	"github.com/rs/zerolog/log"
 	"net/http"
-	"os"
-
 	"strings"
 	ttpl "text/template"
 )
 
+func init() {
+	MustRegister("api", func() Operator { return &API{} })
+}
+
 type API struct {
 	Endpoint     string   `yaml:"api"`
 	OutputFile   string   `yaml:"outputFile"`
@@ -75,75 +77,57 @@ func (api *API) findNestedValue(data map[string]interface{}, keyParts []string)
 	return nil, errors.New("key not found in nested map")
 }
 
-func (api *API) Setup() {
-	api.Endpoint = RenderEnvString(api.Endpoint)
-	api.OutputFile = RenderEnvString(api.OutputFile)
+// Setup renders the endpoint and output file and executes the body template, returning an error if the body
+// template cannot be loaded or executed.
+func (api *API) Setup(ctx context.Context) error {
+	api.Endpoint = RenderEnvString(ctx, api.Endpoint)
+	api.OutputFile = RenderEnvString(ctx, api.OutputFile)
 	if len(api.Body) == 0 {
-		return
+		return nil
 	}
 	// if api.body starts with file:// or https:// or http:// or s3:// then we use load template from remote, else read body as a const string to template
+	var err error
 	if strings.HasPrefix(api.Body, "file://") || strings.HasPrefix(api.Body, "https://") || strings.HasPrefix(api.Body, "http://") || strings.HasPrefix(api.Body, "s3://") {
-		t, err := loadTemplateFromRemote(api.Body)
-		if err != nil {
-			log.Error().Err(err).Msg("failed to load template from remote")
-		} else {
-			api.bodyTemplate = t
-		}
+		api.bodyTemplate, err = loadTemplateFromRemote(ctx, api.Body)
 	} else {
-		if len(api.Body) > 0 {
-			t, err := loadTemplateFromString(api.Body)
-			if err != nil {
-				log.Error().Err(err).Msg("failed to load template from string")
-			} else {
-				api.bodyTemplate = t
-			}
-		}
+		api.bodyTemplate, err = loadTemplateFromString(api.Body)
 	}
-	var envarMap = make(map[string]string)
-	var envars = os.Environ()
-	// foreach environment variable we need to load the value
-	for _, e := range envars {
-		if i := strings.Index(e, "="); i >= 0 {
-			envarMap[e[:i]] = e[i+1:]
-		}
+	if err != nil {
+		return fmt.Errorf("could not load the body template: %w", err)
 	}
 	var doc bytes.Buffer
-	err := api.bodyTemplate.Execute(&doc, envarMap)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to execute template")
-		os.Exit(1)
-		return
+	if err := api.bodyTemplate.Execute(&doc, vars.FromContext(ctx).Map()); err != nil {
+		return fmt.Errorf("could not execute the body template: %w", err)
 	}
 	api.bodyContent = doc.Bytes()
+	return nil
 }
 
 // Execute runs the command.
-func (api *API) Execute() error {
-	api.Setup()
-	/* We do not replace command envars like the other functions, this is intended to be a raw command */
-	if len(api.OnlyIf) > 0 {
-		pc := exe.Run(api.OnlyIf, "")
-		if pc.Failed() || len(pc.Get()) == 0 {
-			log.Info().Msgf("skipping on (onlyIf): %s", api.OnlyIf)
-			return nil
-		}
+func (api *API) Execute(ctx context.Context) (*Result, error) {
+	api = runCopy(api)
+	if err := api.Setup(ctx); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to prepare the request")
+		return &Result{Status: StatusFailed, Stderr: err.Error()}, err
 	}
-	// if notIf is set, check if it's return value is empty / false
-	if len(api.NotIf) > 0 {
-		pc := exe.Run(api.NotIf, "")
-		if !pc.Failed() || len(pc.Get()) > 0 {
-			log.Info().Msgf("skipping on (notIf): %s", api.NotIf)
-			return nil
-		}
+	if r := checkConditions(ctx, api.OnlyIf, api.NotIf); r != nil {
+		return r, nil
 	}
 	if api.Method == "" {
 		api.Method = "GET"
 	}
-	log.Info().Msgf("API request: %s %s", api.Method, api.Endpoint)
-	req, err := http.NewRequest(api.Method, api.Endpoint, bytes.NewBuffer(api.bodyContent))
+	if DryRun() {
+		planned(ctx, "request: %s %s", api.Method, api.Endpoint)
+		if api.OutputFile != "" {
+			planned(ctx, "write response to: %s", api.OutputFile)
+		}
+		return Changed("would request: %s %s", api.Method, api.Endpoint), nil
+	}
+	log.Ctx(ctx).Info().Msgf("API request: %s %s", api.Method, api.Endpoint)
+	req, err := http.NewRequestWithContext(ctx, api.Method, api.Endpoint, bytes.NewBuffer(api.bodyContent))
 	if err != nil {
-		log.Error().Err(err).Msg("failed to create request")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("failed to create request")
+		return nil, err
 	}
 
 	for _, h := range api.Headers {
@@ -155,61 +139,48 @@ func (api *API) Execute() error {
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to do request")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("failed to do request")
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 299 {
-		log.Error().Msgf("API request failed with status: %d", resp.StatusCode)
-		return fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+		log.Ctx(ctx).Error().Msgf("API request failed with status: %d", resp.StatusCode)
+		return &Result{Status: StatusFailed, Stderr: resp.Status}, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
 	}
 	d, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to read response body")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("failed to read response body")
+		return nil, err
 	}
 
+	outputChanged := false
 	if api.OutputFile != "" {
-		// create directories first
-		err = os.MkdirAll(path.Dir(api.OutputFile), 0755)
+		outputChanged, err = writeIfChanged(ctx, api.OutputFile, d, 0644)
 		if err != nil {
-			log.Error().Err(err).Msg("failed to create directories for api content saving")
-			return err
-		}
-		err = os.WriteFile(api.OutputFile, d, 0644)
-		if err != nil {
-			log.Error().Err(err).Msg("failed to write output file")
-			return err
+			log.Ctx(ctx).Error().Err(err).Msg("failed to write output file")
+			return nil, err
 		}
 	}
 
 	if api.EnvId != "" {
-		// set env vars
-		err = os.Setenv(api.EnvId, string(d))
-		if err != nil {
-			log.Error().Err(err).Msg("failed to set env var")
-			return err
-		}
+		vars.FromContext(ctx).Set(api.EnvId, string(d))
 	}
 
 	if len(api.JsonEnv) > 0 && len(api.JsonKey) > 0 {
 		val, err := api.GetJsonMapValue(string(d), api.JsonKey)
 		if err != nil {
-			log.Error().Err(err).Msg("failed to get json map value")
-			return err
-		}
-		err = os.Setenv(api.JsonEnv, val)
-		if err != nil {
-			log.Error().Err(err).Msg("failed to set json env var")
-			return err
+			log.Ctx(ctx).Error().Err(err).Msg("failed to get json map value")
+			return nil, err
 		}
+		vars.FromContext(ctx).Set(api.JsonEnv, val)
 	}
-	log.Debug().Msgf("API response: %s", string(d))
-	if len(api.OutputFile) > 0 {
-		log.Info().Msgf("API content saved to: %s", api.OutputFile)
-		return nil
+	log.Ctx(ctx).Debug().Msgf("API response: %s", string(d))
+	res := Changed("%s %s: %d", api.Method, api.Endpoint, resp.StatusCode)
+	res.Stdout = string(d)
+	if outputChanged {
+		log.Ctx(ctx).Info().Msgf("API content saved to: %s", api.OutputFile)
+		res.WithFiles(api.OutputFile)
 	}
-
-	return nil
+	return res, nil
 }
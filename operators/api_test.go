@@ -0,0 +1,83 @@
+package operators
+
+import (
+	"bruce/vars"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAPIOutputFile(t *testing.T) {
+	body := "first"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+	out := filepath.Join(t.TempDir(), "api", "response.json")
+	tests := []struct {
+		name      string
+		body      string
+		wantFiles []string
+	}{
+		{name: "created", body: "first", wantFiles: []string{out}},
+		{name: "unchanged", body: "first"},
+		{name: "updated", body: "second", wantFiles: []string{out}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body = tt.body
+			res, err := (&API{Endpoint: srv.URL, OutputFile: out}).Execute(context.Background())
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !reflect.DeepEqual(res.Files, tt.wantFiles) {
+				t.Errorf("Execute() files = %v, want %v", res.Files, tt.wantFiles)
+			}
+			if d, _ := os.ReadFile(out); string(d) != tt.body {
+				t.Errorf("Execute() output file = %q, want %q", d, tt.body)
+			}
+		})
+	}
+}
+
+func TestAPIBodyTemplate(t *testing.T) {
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, _ := io.ReadAll(r.Body)
+		received = append(received, string(d))
+	}))
+	defer srv.Close()
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "rendered body", body: `{"name": "{{.NAME}}"}`, want: []string{`{"name": "web"}`}},
+		{name: "invalid template", body: `{"name": "{{.NAME"}`, wantErr: true},
+		{name: "failing template", body: `{{template "missing"}}`, wantErr: true},
+		{name: "missing remote template", body: "file://" + filepath.Join(t.TempDir(), "missing.tmpl"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = nil
+			ctx := vars.WithScope(context.Background(), vars.New(map[string]string{"NAME": "web"}))
+			res, err := (&API{Endpoint: srv.URL, Method: "POST", Body: tt.body}).Execute(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && (res == nil || res.Status != StatusFailed) {
+				t.Errorf("Execute() = %v, want a failed result", res)
+			}
+			if !reflect.DeepEqual(received, tt.want) {
+				t.Errorf("Execute() sent %q, want %q", received, tt.want)
+			}
+		})
+	}
+}
@@ -3,11 +3,17 @@ package operators
 import (
 	"bruce/exe"
 	"bruce/system"
+	"bruce/vars"
+	"context"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"os"
 )
 
+func init() {
+	MustRegister("cmd", func() Operator { return &Command{} })
+}
+
 type Command struct {
 	Cmd        string `yaml:"cmd"`
 	WorkingDir string `yaml:"dir"`
@@ -18,59 +24,51 @@ type Command struct {
 	EnvCmd     string
 }
 
-func (c *Command) Setup() {
-	c.WorkingDir = RenderEnvString(c.WorkingDir)
-	c.EnvCmd = RenderEnvString(c.Cmd)
+func (c *Command) Setup(ctx context.Context) {
+	c.WorkingDir = RenderEnvString(ctx, c.WorkingDir)
+	c.EnvCmd = RenderEnvString(ctx, c.Cmd)
 }
 
 // Execute runs the command.
-func (c *Command) Execute() error {
-	c.Setup()
+func (c *Command) Execute(ctx context.Context) (*Result, error) {
+	c = runCopy(c)
+	c.Setup(ctx)
 	/* We do not replace command envars like the other functions, this is intended to be a raw command */
-	if system.Get().CanExecOnOs(c.OsLimits) {
-		// if onlyIf is set, check if it's return value is not empty / true
-		if len(c.OnlyIf) > 0 {
-			pc := exe.Run(c.OnlyIf, "")
-			if pc.Failed() || len(pc.Get()) == 0 {
-				log.Info().Msgf("skipping on (onlyIf): %s", c.OnlyIf)
-				return nil
-			}
-		}
-		// if notIf is set, check if it's return value is empty / false
-		if len(c.NotIf) > 0 {
-			pc := exe.Run(c.NotIf, "")
-			if !pc.Failed() || len(pc.Get()) > 0 {
-				log.Info().Msgf("skipping on (notIf): %s", c.NotIf)
-				return nil
-			}
-		}
-		if len(c.EnvCmd) < 1 {
-			return fmt.Errorf("no command to execute")
-		}
-		log.Info().Msgf("cmd: %s", c.EnvCmd)
-		fileName := exe.EchoToFile(c.EnvCmd, os.TempDir())
-		// change directory to the working directory if specified
-		err := os.Chmod(fileName, 0775)
-		if err != nil {
-			log.Error().Err(err).Msg("temp file must exist to continue")
-			return err
-		}
-		log.Debug().Str("command", c.EnvCmd).Msgf("executing local file: %s", fileName)
-		pc := exe.Run(fileName, c.WorkingDir)
-		if pc.Failed() {
-			log.Error().Err(pc.GetErr()).Msg(pc.Get())
-			return pc.GetErr()
-		} else {
-			log.Debug().Str("cmd", c.EnvCmd).Msgf("completed executing: %s", fileName)
-			log.Debug().Msgf("Output: %s", pc.Get())
-			if len(c.SetEnv) > 0 {
-				log.Debug().Str("cmd", c.EnvCmd).Msgf("setting env var: %s=%s", c.SetEnv, pc.Get())
-				log.Error().Err(os.Setenv(c.SetEnv, pc.Get()))
-			}
-			log.Error().Err(os.Remove(fileName))
-		}
-	} else {
-		log.Info().Str("cmd", c.EnvCmd).Msgf("skipped due to os limit: %s", c.OsLimits)
+	if !system.Get().CanExecOnOs(c.OsLimits) {
+		log.Ctx(ctx).Info().Str("cmd", c.EnvCmd).Msgf("skipped due to os limit: %s", c.OsLimits)
+		return Skipped("os limit: %s", c.OsLimits), nil
+	}
+	if r := checkConditions(ctx, c.OnlyIf, c.NotIf); r != nil {
+		return r, nil
+	}
+	if len(c.EnvCmd) < 1 {
+		return nil, fmt.Errorf("no command to execute")
+	}
+	if DryRun() {
+		// a raw command cannot be predicted, only the conditions above can tell us it would be skipped
+		planned(ctx, "run: %s", c.EnvCmd)
+		return Changed("would run: %s", c.EnvCmd), nil
+	}
+	log.Ctx(ctx).Info().Msgf("cmd: %s", c.EnvCmd)
+	fileName := exe.EchoToFile(c.EnvCmd, os.TempDir())
+	// change directory to the working directory if specified
+	err := os.Chmod(fileName, 0775)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("temp file must exist to continue")
+		return nil, err
+	}
+	log.Ctx(ctx).Debug().Str("command", c.EnvCmd).Msgf("executing local file: %s", fileName)
+	pc := exe.RunContext(ctx, fileName, c.WorkingDir)
+	if pc.Failed() {
+		log.Ctx(ctx).Error().Err(pc.GetErr()).Msg(pc.Get())
+		return (&Result{Status: StatusFailed}).WithOutput(pc), pc.GetErr()
+	}
+	log.Ctx(ctx).Debug().Str("cmd", c.EnvCmd).Msgf("completed executing: %s", fileName)
+	log.Ctx(ctx).Debug().Msgf("Output: %s", pc.Get())
+	if len(c.SetEnv) > 0 {
+		log.Ctx(ctx).Debug().Str("cmd", c.EnvCmd).Msgf("setting env var: %s=%s", c.SetEnv, pc.Get())
+		vars.FromContext(ctx).Set(c.SetEnv, pc.Get())
 	}
-	return nil
+	log.Ctx(ctx).Error().Err(os.Remove(fileName))
+	return Changed("ran: %s", c.EnvCmd).WithOutput(pc), nil
 }
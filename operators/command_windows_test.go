@@ -4,6 +4,7 @@
 package operators
 
 import (
+	"context"
 	"testing"
 )
 
@@ -34,7 +35,7 @@ func TestCommand_Execute(t *testing.T) {
 			c := &Command{
 				Cmd: tt.fields.Cmd,
 			}
-			if err := c.Execute(); (err != nil) != tt.wantErr {
+			if _, err := c.Execute(context.Background()); (err != nil) != tt.wantErr {
 				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
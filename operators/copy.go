@@ -1,47 +1,60 @@
 package operators
 
 import (
-	"bruce/exe"
 	"bruce/loader"
+	"context"
 	"github.com/rs/zerolog/log"
 	"io/fs"
 )
 
+func init() {
+	MustRegister("copy", func() Operator { return &Copy{} })
+}
+
 type Copy struct {
 	Src    string      `yaml:"copy"`
 	Dest   string      `yaml:"dest"`
 	Perm   fs.FileMode `yaml:"perm"`
+	Owner  string      `yaml:"owner"`
+	Group  string      `yaml:"group"`
 	OnlyIf string      `yaml:"onlyIf"`
 	NotIf  string      `yaml:"notIf"`
 }
 
-func (c *Copy) Setup() {
-	c.Src = RenderEnvString(c.Src)
-	c.Dest = RenderEnvString(c.Dest)
+func (c *Copy) Setup(ctx context.Context) {
+	c.Src = RenderEnvString(ctx, c.Src)
+	c.Dest = RenderEnvString(ctx, c.Dest)
+	c.Owner = RenderEnvString(ctx, c.Owner)
+	c.Group = RenderEnvString(ctx, c.Group)
 }
 
-func (c *Copy) Execute() error {
-	c.Setup()
-	if len(c.OnlyIf) > 0 {
-		pc := exe.Run(c.OnlyIf, "")
-		if pc.Failed() || len(pc.Get()) == 0 {
-			log.Info().Msgf("skipping on (onlyIf): %s", c.OnlyIf)
-			return nil
-		}
+func (c *Copy) Execute(ctx context.Context) (*Result, error) {
+	c = runCopy(c)
+	c.Setup(ctx)
+	if r := checkConditions(ctx, c.OnlyIf, c.NotIf); r != nil {
+		return r, nil
 	}
-	// if notIf is set, check if it's return value is empty / false
-	if len(c.NotIf) > 0 {
-		pc := exe.Run(c.NotIf, "")
-		if !pc.Failed() || len(pc.Get()) > 0 {
-			log.Info().Msgf("skipping on (notIf): %s", c.NotIf)
-			return nil
-		}
+	r, _, err := loader.GetRemoteReaderContext(ctx, c.Src)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot open source file")
+		return nil, err
 	}
-	err := loader.CopyFile(c.Src, c.Dest, c.Perm, true)
-	log.Info().Msgf("copy: %s => %s", c.Src, c.Dest)
+	defer r.Close()
+	log.Ctx(ctx).Info().Msgf("copy: %s => %s", c.Src, c.Dest)
+	changed, err := streamIfChanged(ctx, c.Dest, r, c.Perm)
 	if err != nil {
-		log.Error().Err(err).Msg("could not copy file")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("could not copy file")
+		return nil, err
+	}
+	if DryRun() && changed {
+		return Changed("would copy: %s => %s", c.Src, c.Dest).WithFiles(c.Dest), nil
+	}
+	n, err := setOwnership(ctx, c.Dest, c.Owner, c.Group, c.Perm, 0, false)
+	if err != nil {
+		return nil, err
+	}
+	if changed || n > 0 {
+		return Changed("copied: %s => %s", c.Src, c.Dest).WithFiles(c.Dest), nil
 	}
-	return nil
+	return Ok("unchanged: %s", c.Dest), nil
 }
@@ -0,0 +1,56 @@
+package operators
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyExecute(t *testing.T) {
+	tests := []struct {
+		name       string
+		existing   string
+		dryRun     bool
+		wantStatus Status
+		wantDest   string
+	}{
+		{name: "create", wantStatus: StatusChanged, wantDest: "new content"},
+		{name: "update", existing: "old content", wantStatus: StatusChanged, wantDest: "new content"},
+		{name: "unchanged", existing: "new content", wantStatus: StatusOk, wantDest: "new content"},
+		{name: "dry run", existing: "old content", dryRun: true, wantStatus: StatusChanged, wantDest: "old content"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src, dest := filepath.Join(dir, "src.conf"), filepath.Join(dir, "etc", "dest.conf")
+			if err := os.WriteFile(src, []byte("new content"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.existing) > 0 {
+				if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(dest, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			SetDryRun(tt.dryRun)
+			defer SetDryRun(false)
+			res, err := (&Copy{Src: src, Dest: dest}).Execute(context.Background())
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if res.Status != tt.wantStatus {
+				t.Errorf("Execute() status = %s, want %s (%s)", res.Status, tt.wantStatus, res.Reason)
+			}
+			if d, _ := os.ReadFile(dest); string(d) != tt.wantDest {
+				t.Errorf("Execute() dest = %q, want %q", d, tt.wantDest)
+			}
+			// only the destination remains, the staged temporary file is removed
+			if entries, _ := os.ReadDir(filepath.Dir(dest)); len(tt.existing) > 0 && len(entries) != 1 {
+				t.Errorf("Execute() left %d files next to the destination, want 1", len(entries))
+			}
+		})
+	}
+}
@@ -1,14 +1,18 @@
 package operators
 
 import (
-	"bruce/exe"
 	"bruce/mutation"
 	"bruce/system"
+	"context"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"runtime"
 )
 
+func init() {
+	MustRegister("cron", func() Operator { return &Cron{} })
+}
+
 // Cron provides a means to set the ownership of files or directories as needed.
 type Cron struct {
 	Name     string `yaml:"cron"`
@@ -19,38 +23,39 @@ type Cron struct {
 	NotIf    string `yaml:"notIf"`
 }
 
-func (c *Cron) Setup() {
-	c.Exec = RenderEnvString(c.Exec)
-	c.User = RenderEnvString(c.User)
+func (c *Cron) Setup(ctx context.Context) {
+	c.Exec = RenderEnvString(ctx, c.Exec)
+	c.User = RenderEnvString(ctx, c.User)
 }
 
-func (c *Cron) Execute() error {
-	c.Setup()
-	if runtime.GOOS == "linux" {
-		if len(c.OnlyIf) > 0 {
-			pc := exe.Run(c.OnlyIf, "")
-			if pc.Failed() || len(pc.Get()) == 0 {
-				log.Info().Msgf("skipping on (onlyIf): %s", c.OnlyIf)
-				return nil
-			}
-		}
-		// if notIf is set, check if it's return value is empty / false
-		if len(c.NotIf) > 0 {
-			pc := exe.Run(c.NotIf, "")
-			if !pc.Failed() || len(pc.Get()) > 0 {
-				log.Info().Msgf("skipping on (notIf): %s", c.NotIf)
-				return nil
-			}
-		}
-		jobName := mutation.StripNonAlnum(c.Name)
-		log.Info().Msgf("cron: /etc/cron.d/%s", jobName)
-		c.Schedule = mutation.StripExtraWhitespaceFB(c.Schedule)
-		c.User = mutation.StripNonAlnum(c.User)
-		log.Debug().Msgf("starting cronjob: %s", jobName)
-		if c.User == "" {
-			c.User = system.Get().CurrentUser.Username
-		}
-		return mutation.WriteInlineTemplate(fmt.Sprintf("/etc/cron.d/%s", jobName), "{{.Schedule}} {{.User}} {{.Exec}}", c)
+func (c *Cron) Execute(ctx context.Context) (*Result, error) {
+	c = runCopy(c)
+	c.Setup(ctx)
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("not supported")
+	}
+	if r := checkConditions(ctx, c.OnlyIf, c.NotIf); r != nil {
+		return r, nil
+	}
+	jobName := mutation.StripNonAlnum(c.Name)
+	cronFile := fmt.Sprintf("/etc/cron.d/%s", jobName)
+	log.Ctx(ctx).Info().Msgf("cron: %s", cronFile)
+	c.Schedule = mutation.StripExtraWhitespaceFB(c.Schedule)
+	c.User = mutation.StripNonAlnum(c.User)
+	log.Ctx(ctx).Debug().Msgf("starting cronjob: %s", jobName)
+	if c.User == "" {
+		c.User = system.Get().CurrentUser.Username
+	}
+	d, err := mutation.RenderInlineTemplate("{{.Schedule}} {{.User}} {{.Exec}}", c)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := writeIfChanged(ctx, cronFile, d, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		return Changed("cron written: %s", cronFile).WithFiles(cronFile), nil
 	}
-	return fmt.Errorf("not supported")
+	return Ok("cron unchanged: %s", cronFile), nil
 }
@@ -0,0 +1,77 @@
+package operators
+
+import (
+	"bruce/mutation"
+	"context"
+	"io/fs"
+	"os"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxDiffSize is the largest file content that will be diffed during a dry run.
+const maxDiffSize = 256 * 1024
+
+var dryRun atomic.Bool
+
+// SetDryRun enables check mode, operators report what they would change without mutating the system.
+func SetDryRun(enabled bool) {
+	dryRun.Store(enabled)
+}
+
+// DryRun returns true if operators should only report their changes.
+func DryRun() bool {
+	return dryRun.Load()
+}
+
+// planned logs an action that would have been taken if this was not a dry run.
+func planned(ctx context.Context, format string, args ...interface{}) {
+	log.Ctx(ctx).Info().Bool("dryRun", true).Msgf("would "+format, args...)
+}
+
+// planFile reports whether writing content to fileName would create or update the file and returns true if it would
+// change, text content is logged as a diff against the existing file.
+func planFile(ctx context.Context, fileName string, content []byte) bool {
+	existing, err := os.ReadFile(fileName)
+	if err != nil {
+		planned(ctx, "create: %s (%d bytes)", fileName, len(content))
+		if isDiffable(content) {
+			log.Ctx(ctx).Info().Msg(mutation.Diff("/dev/null", fileName, "", string(content)))
+		}
+		return true
+	}
+	if string(existing) == string(content) {
+		log.Ctx(ctx).Info().Msgf("unchanged: %s", fileName)
+		return false
+	}
+	planned(ctx, "update: %s", fileName)
+	if isDiffable(existing) && isDiffable(content) {
+		log.Ctx(ctx).Info().Msg(mutation.Diff(fileName, fileName, string(existing), string(content)))
+	} else {
+		log.Ctx(ctx).Info().Msgf("binary content differs (%d bytes => %d bytes)", len(existing), len(content))
+	}
+	return true
+}
+
+func isDiffable(d []byte) bool {
+	return len(d) <= maxDiffSize && utf8.Valid(d)
+}
+
+// planOwnership reports the owner, group and mode changes setOwnership would apply to fileName and returns true if
+// any would change, a file that does not exist yet is planned with everything requested.
+func planOwnership(ctx context.Context, fileName, owner, group string, mode fs.FileMode) (bool, error) {
+	if owner == "" && group == "" && mode == 0 {
+		return false, nil
+	}
+	if _, err := os.Lstat(fileName); err != nil {
+		planned(ctx, "set ownership: %s (%s:%s %o)", fileName, owner, group, mode)
+		return true, nil
+	}
+	n, err := setOwnership(ctx, fileName, owner, group, mode, 0, false)
+	if n > 0 {
+		planned(ctx, "change ownership: %s (%s:%s %o)", fileName, owner, group, mode)
+	}
+	return n > 0, err
+}
@@ -0,0 +1,105 @@
+package operators
+
+import (
+	"bruce/backup"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+// writeIfChanged only writes the file when the content differs from what is on disk, returning true if written.
+// The content is written to a temporary file and renamed into place so running binaries can be replaced.
+func writeIfChanged(ctx context.Context, fileName string, content []byte, perm os.FileMode) (bool, error) {
+	return streamIfChanged(ctx, fileName, bytes.NewReader(content), perm)
+}
+
+// streamIfChanged is writeIfChanged for content read from r, the content is streamed to a temporary file next to
+// fileName and hashed so it is never held in memory. The temporary file is only renamed into place if it differs.
+func streamIfChanged(ctx context.Context, fileName string, r io.Reader, perm os.FileMode) (bool, error) {
+	// a dry run must not create the destination directory so the content is staged in the system temp directory
+	dir := ""
+	if !DryRun() {
+		dir = filepath.Dir(fileName)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("cannot create directory for: %s", fileName)
+			return false, err
+		}
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(fileName)+".*")
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("could not create temporary file for: %s", fileName)
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		tmp.Close()
+		log.Ctx(ctx).Error().Err(err).Msgf("could not write file: %s", fileName)
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if existing, err := fileHash(fileName); err == nil && bytes.Equal(existing, h.Sum(nil)) {
+		log.Ctx(ctx).Debug().Msgf("no changes for: %s", fileName)
+		return false, nil
+	}
+	if DryRun() {
+		return planStaged(ctx, fileName, tmp.Name(), size)
+	}
+	if perm == 0 {
+		perm = 0644
+	}
+	// keep the previous file so the change can be rolled back
+	if err := backup.Save(ctx, fileName); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("could not back up: %s", fileName)
+		return false, err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp.Name(), fileName); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("could not write file: %s", fileName)
+		return false, err
+	}
+	log.Ctx(ctx).Debug().Msgf("wrote: %s", fileName)
+	return true, nil
+}
+
+// planStaged reports the change of writing the staged file to fileName, content small enough to diff is planned
+// with planFile.
+func planStaged(ctx context.Context, fileName, staged string, size int64) (bool, error) {
+	if size <= maxDiffSize {
+		content, err := os.ReadFile(staged)
+		if err != nil {
+			return false, err
+		}
+		return planFile(ctx, fileName, content), nil
+	}
+	if _, err := os.Stat(fileName); err != nil {
+		planned(ctx, "create: %s (%d bytes)", fileName, size)
+		return true, nil
+	}
+	planned(ctx, "update: %s (%d bytes)", fileName, size)
+	return true, nil
+}
+
+// fileHash returns the sha256 of the file content, reading the file in chunks.
+func fileHash(fileName string) ([]byte, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
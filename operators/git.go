@@ -1,14 +1,18 @@
 package operators
 
 import (
-	"bruce/exe"
 	"bruce/system"
+	"context"
 	"github.com/go-git/go-git/v5"
 	"github.com/rs/zerolog/log"
 	"os"
 	"path"
 )
 
+func init() {
+	MustRegister("gitRepo", func() Operator { return &Git{} })
+}
+
 type Git struct {
 	Repo     string `yaml:"gitRepo"`
 	Location string `yaml:"dest"`
@@ -17,54 +21,47 @@ type Git struct {
 	NotIf    string `yaml:"notIf"`
 }
 
-func (g *Git) Setup() {
-	g.Repo = RenderEnvString(g.Repo)
-	g.Location = RenderEnvString(g.Location)
+func (g *Git) Setup(ctx context.Context) {
+	g.Repo = RenderEnvString(ctx, g.Repo)
+	g.Location = RenderEnvString(ctx, g.Location)
+	if DryRun() {
+		return
+	}
 	// make the destination directory without the last path element
 	target := path.Dir(g.Location)
 	err := os.MkdirAll(target, 0755)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to create git destination directory for git clone")
+		log.Ctx(ctx).Error().Err(err).Msg("failed to create git destination directory for git clone")
 	}
 }
 
-// Execute runs the command.
-func (g *Git) Execute() error {
-	g.Setup()
-	/* We do not replace command envars like the other functions, this is intended to be a raw command */
-	if system.Get().CanExecOnOs(g.OsLimits) {
-		if len(g.OnlyIf) > 0 {
-			pc := exe.Run(g.OnlyIf, "")
-			if pc.Failed() || len(pc.Get()) == 0 {
-				log.Info().Msgf("skipping on (onlyIf): %s", g.OnlyIf)
-				return nil
-			}
-		}
-		// if notIf is set, check if it's return value is empty / false
-		if len(g.NotIf) > 0 {
-			pc := exe.Run(g.NotIf, "")
-			if !pc.Failed() || len(pc.Get()) > 0 {
-				log.Info().Msgf("skipping on (notIf): %s", g.NotIf)
-				return nil
-			}
-		}
-		// if directory exists and it contains a .git directory, just return
-		if _, err := os.Stat(path.Join(g.Location, ".git")); err == nil {
-			log.Info().Msgf("git repo already exists: %s", g.Location)
-			return nil
-		}
-
-		_, err := git.PlainClone(g.Location, false, &git.CloneOptions{
-			URL:      g.Repo,
-			Progress: os.Stdout,
-		})
-		if err != nil {
-			log.Error().Err(err).Msg("failed to clone repo")
-			return err
-		}
-		log.Info().Msgf("git cloned: %s to %s", g.Repo, g.Location)
-	} else {
-		log.Info().Str("git", g.Repo).Msgf("skipped due to os limit: %s", g.OsLimits)
+// Execute clones the repository unless it already exists.
+func (g *Git) Execute(ctx context.Context) (*Result, error) {
+	g = runCopy(g)
+	g.Setup(ctx)
+	if !system.Get().CanExecOnOs(g.OsLimits) {
+		log.Ctx(ctx).Info().Str("git", g.Repo).Msgf("skipped due to os limit: %s", g.OsLimits)
+		return Skipped("os limit: %s", g.OsLimits), nil
+	}
+	if r := checkConditions(ctx, g.OnlyIf, g.NotIf); r != nil {
+		return r, nil
+	}
+	// if directory exists and it contains a .git directory, just return
+	if _, err := os.Stat(path.Join(g.Location, ".git")); err == nil {
+		log.Ctx(ctx).Info().Msgf("git repo already exists: %s", g.Location)
+		return Ok("git repo already exists: %s", g.Location), nil
+	}
+	if DryRun() {
+		planned(ctx, "clone: %s => %s", g.Repo, g.Location)
+		return Changed("would clone: %s => %s", g.Repo, g.Location).WithFiles(g.Location), nil
+	}
+	_, err := git.PlainCloneContext(ctx, g.Location, false, &git.CloneOptions{
+		URL: g.Repo,
+	})
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to clone repo")
+		return nil, err
 	}
-	return nil
+	log.Ctx(ctx).Info().Msgf("git cloned: %s to %s", g.Repo, g.Location)
+	return Changed("cloned: %s => %s", g.Repo, g.Location).WithFiles(g.Location), nil
 }
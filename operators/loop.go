@@ -3,11 +3,17 @@ package operators
 import (
 	"bruce/exe"
 	"bruce/system"
+	"bruce/vars"
+	"context"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"os"
 )
 
+func init() {
+	MustRegister("loopScript", func() Operator { return &Loop{} })
+}
+
 type Loop struct {
 	LoopScript string `yaml:"loopScript"`
 	Count      int    `yaml:"count"`
@@ -17,44 +23,39 @@ type Loop struct {
 	NotIf      string `yaml:"notIf"`
 }
 
-func (lp *Loop) Setup() {
+func (lp *Loop) Setup(ctx context.Context) {
 
 }
 
-// Execute runs the command.
-func (lp *Loop) Execute() error {
-	lp.Setup()
-	/* We do not replace command envars like the other functions, this is intended to be a raw command */
-	if system.Get().CanExecOnOs(lp.OsLimits) {
-		// if onlyIf is set, check if it's return value is not empty / true
-		if len(lp.OnlyIf) > 0 {
-			pc := exe.Run(lp.OnlyIf, "")
-			if pc.Failed() || len(pc.Get()) == 0 {
-				log.Info().Msgf("skipping on (onlyIf): %s", lp.OnlyIf)
-				return nil
-			}
-		}
-		// if notIf is set, check if it's return value is empty / false
-		if len(lp.NotIf) > 0 {
-			pc := exe.Run(lp.NotIf, "")
-			if !pc.Failed() || len(pc.Get()) > 0 {
-				log.Info().Msgf("skipping on (notIf): %s", lp.NotIf)
-				return nil
-			}
-		}
-		for i := 0; i < lp.Count; i++ {
-			log.Info().Str("loop", lp.LoopScript).Msgf("executing: %s with variable: %s and value: %d", lp.LoopScript, lp.Variable, i)
-			log.Error().Err(os.Setenv(lp.Variable, fmt.Sprintf("%d", i)))
-			// get current running file and append the loop script as the first argument
-			execCmd := fmt.Sprintf("%s %s", os.Args[0], lp.LoopScript)
-			pc := exe.Run(execCmd, "")
-			if pc.Failed() {
-				log.Error().Err(pc.GetErr()).Msg(pc.Get())
-				return pc.GetErr()
-			}
+// Execute runs the loop script count times with the loop variable set.
+func (lp *Loop) Execute(ctx context.Context) (*Result, error) {
+	lp.Setup(ctx)
+	if !system.Get().CanExecOnOs(lp.OsLimits) {
+		log.Ctx(ctx).Info().Str("loop", lp.LoopScript).Msgf("skipped due to os limit: %s", lp.OsLimits)
+		return Skipped("os limit: %s", lp.OsLimits), nil
+	}
+	if r := checkConditions(ctx, lp.OnlyIf, lp.NotIf); r != nil {
+		return r, nil
+	}
+	if DryRun() {
+		planned(ctx, "run: %s %d times with variable: %s", lp.LoopScript, lp.Count, lp.Variable)
+		return Changed("would run: %s %d times", lp.LoopScript, lp.Count), nil
+	}
+	res := Changed("ran: %s %d times", lp.LoopScript, lp.Count)
+	for i := 0; i < lp.Count; i++ {
+		log.Ctx(ctx).Info().Str("loop", lp.LoopScript).Msgf("executing: %s with variable: %s and value: %d", lp.LoopScript, lp.Variable, i)
+		// the loop variable is only exported to the loop script
+		loopCtx := vars.WithScope(ctx, vars.FromContext(ctx).Child(map[string]string{lp.Variable: fmt.Sprintf("%d", i)}))
+		// get current running file and append the loop script as the first argument
+		execCmd := fmt.Sprintf("%s %s", os.Args[0], lp.LoopScript)
+		pc := exe.RunContext(loopCtx, execCmd, "")
+		res.Stdout += pc.Stdout()
+		res.Stderr += pc.Stderr()
+		if pc.Failed() {
+			log.Ctx(ctx).Error().Err(pc.GetErr()).Msg(pc.Get())
+			res.Status = StatusFailed
+			return res, pc.GetErr()
 		}
-	} else {
-		log.Info().Str("loop", lp.LoopScript).Msgf("skipped due to os limit: %s", lp.OsLimits)
 	}
-	return nil
+	return res, nil
 }
@@ -2,6 +2,8 @@ package operators
 
 import (
 	"bruce/system"
+	"bruce/vars"
+	"context"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"os"
@@ -10,15 +12,23 @@ import (
 	"strings"
 )
 
+// Operator is implemented by every manifest step action, the returned result reports what the execution did.
+// The context is cancelled when the step times out so long running processes and requests should honour it.
 type Operator interface {
-	Execute() error
+	Execute(ctx context.Context) (*Result, error)
+}
+
+// Relocator is implemented by operators reading sources that may be given relative to the role defining the step,
+// resolve returns the location of a relative source and leaves other locations unchanged.
+type Relocator interface {
+	Relocate(resolve func(string) string)
 }
 
 type NullOperator struct {
 }
 
-func (n *NullOperator) Execute() error {
-	return fmt.Errorf("invalid operator")
+func (n *NullOperator) Execute(ctx context.Context) (*Result, error) {
+	return nil, fmt.Errorf("invalid operator")
 }
 
 func GetValueForOSHandler(value string) string {
@@ -55,13 +65,10 @@ func GetValueForOSHandler(value string) string {
 	return value
 }
 
-func RenderEnvString(s string) string {
-	log.Debug().Msgf("rendering env string: %s", s)
-	envVars := make(map[string]string)
-	for _, e := range os.Environ() {
-		pair := strings.SplitN(e, "=", 2)
-		envVars[pair[0]] = pair[1]
-	}
+// RenderEnvString replaces the variable references in s with their values from the variable scope of ctx.
+func RenderEnvString(ctx context.Context, s string) string {
+	log.Ctx(ctx).Debug().Msgf("rendering env string: %s", s)
+	envVars := vars.FromContext(ctx)
 
 	var envVarRegex *regexp.Regexp
 	if system.Get().OSType == "windows" {
@@ -79,6 +86,14 @@ func RenderEnvString(s string) string {
 			return filepath.Join(homeDir, match[1:])
 		}
 		varName := envVarRegex.ReplaceAllString(match, "$1")
-		return envVars[varName]
+		return envVars.Get(varName)
 	})
 }
+
+// runCopy returns a copy of the operator for a single execution. Setup renders the run variables into the fields of
+// the copy so the manifest values are kept for the next run of the same manifest, Setup must not modify slices in
+// place as they are shared with the manifest.
+func runCopy[T any](op *T) *T {
+	c := *op
+	return &c
+}
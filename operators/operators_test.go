@@ -1,6 +1,11 @@
 package operators
 
-import "testing"
+import (
+	"bruce/vars"
+	"context"
+	"runtime"
+	"testing"
+)
 
 func TestNullOperator_Execute(t *testing.T) {
 	tests := []struct {
@@ -15,9 +20,34 @@ func TestNullOperator_Execute(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			n := &NullOperator{}
-			if err := n.Execute(); (err != nil) != tt.wantErr {
+			if _, err := n.Execute(context.Background()); (err != nil) != tt.wantErr {
 				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestRenderEnvString(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows uses %VAR% references")
+	}
+	t.Setenv("BRUCE_RENDER_PROCESS", "process")
+	ctx := vars.WithScope(context.Background(), vars.New(map[string]string{"BRUCE_RENDER_RUN": "run"}))
+	tests := []struct {
+		name string
+		ctx  context.Context
+		in   string
+		want string
+	}{
+		{name: "run variable", ctx: ctx, in: "/opt/${BRUCE_RENDER_RUN}", want: "/opt/run"},
+		{name: "process fallback", ctx: ctx, in: "${BRUCE_RENDER_PROCESS}", want: "process"},
+		{name: "not in another run", ctx: context.Background(), in: "${BRUCE_RENDER_RUN}", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderEnvString(tt.ctx, tt.in); got != tt.want {
+				t.Errorf("RenderEnvString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
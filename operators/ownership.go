@@ -0,0 +1,79 @@
+package operators
+
+import (
+	"bruce/exe"
+	"bruce/system"
+	"context"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io/fs"
+)
+
+func init() {
+	MustRegister("ownership", func() Operator { return &Ownership{} })
+}
+
+// Ownership sets the owner, group and mode of a file or directory tree, owner and group may be names or numeric ids.
+type Ownership struct {
+	Path      string      `yaml:"ownership"`
+	Owner     string      `yaml:"owner"`
+	Group     string      `yaml:"group"`
+	Mode      fs.FileMode `yaml:"mode"`
+	DirMode   fs.FileMode `yaml:"dirMode"`
+	Recursive bool        `yaml:"recursive"`
+	OsLimits  string      `yaml:"osLimits"`
+	OnlyIf    string      `yaml:"onlyIf"`
+	NotIf     string      `yaml:"notIf"`
+}
+
+func (o *Ownership) Setup(ctx context.Context) {
+	o.Path = RenderEnvString(ctx, o.Path)
+	o.Owner = RenderEnvString(ctx, o.Owner)
+	o.Group = RenderEnvString(ctx, o.Group)
+}
+
+func (o *Ownership) Execute(ctx context.Context) (*Result, error) {
+	o = runCopy(o)
+	o.Setup(ctx)
+	if !system.Get().CanExecOnOs(o.OsLimits) {
+		log.Ctx(ctx).Info().Str("ownership", o.Path).Msgf("skipped due to os limit: %s", o.OsLimits)
+		return Skipped("os limit: %s", o.OsLimits), nil
+	}
+	if r := checkConditions(ctx, o.OnlyIf, o.NotIf); r != nil {
+		return r, nil
+	}
+	if len(o.Path) == 0 {
+		return nil, fmt.Errorf("no path provided for ownership")
+	}
+	log.Ctx(ctx).Info().Msgf("ownership: %s (%s:%s %o)", o.Path, o.Owner, o.Group, o.Mode)
+	n, err := setOwnership(ctx, o.Path, o.Owner, o.Group, o.Mode, o.DirMode, o.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return Ok("ownership unchanged: %s", o.Path), nil
+	}
+	if DryRun() {
+		log.Ctx(ctx).Info().Msgf("ownership would change on %d path(s)", n)
+		return Changed("would change ownership on %d path(s)", n).WithFiles(o.Path), nil
+	}
+	log.Ctx(ctx).Info().Msgf("ownership changed on %d path(s)", n)
+	return Changed("ownership changed on %d path(s)", n).WithFiles(o.Path), nil
+}
+
+// setOwnership resolves the owner / group and applies them with the mode, returning the number of paths changed.
+func setOwnership(ctx context.Context, p, owner, group string, mode, dirMode fs.FileMode, recursive bool) (int, error) {
+	if owner == "" && group == "" && mode == 0 && dirMode == 0 {
+		return 0, nil
+	}
+	uid, gid, err := exe.LookupIds(owner, group)
+	if err != nil {
+		return 0, err
+	}
+	n, err := exe.ApplyOwnership(p, uid, gid, mode, dirMode, recursive, DryRun())
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("could not set ownership on: %s", p)
+		return n, err
+	}
+	return n, nil
+}
@@ -0,0 +1,130 @@
+//go:build !windows
+
+package operators
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// ownershipTree creates a directory with a file and a sub directory holding a file, all with mode 0600 / 0700.
+func ownershipTree(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "tree")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"a.conf", "sub/b.conf"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte(f), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestOwnershipExecute(t *testing.T) {
+	uid, gid := strconv.Itoa(os.Getuid()), strconv.Itoa(os.Getgid())
+	tests := []struct {
+		name       string
+		op         func(dir string) *Ownership
+		wantStatus Status
+		wantModes  map[string]fs.FileMode
+	}{
+		{
+			name:       "single file mode",
+			op:         func(dir string) *Ownership { return &Ownership{Path: filepath.Join(dir, "a.conf"), Mode: 0644} },
+			wantStatus: StatusChanged,
+			wantModes:  map[string]fs.FileMode{"a.conf": 0644, "sub/b.conf": 0600, "sub": 0700},
+		},
+		{
+			name:       "recursive with dir mode",
+			op:         func(dir string) *Ownership { return &Ownership{Path: dir, Mode: 0640, DirMode: 0750, Recursive: true} },
+			wantStatus: StatusChanged,
+			wantModes:  map[string]fs.FileMode{".": 0750, "a.conf": 0640, "sub": 0750, "sub/b.conf": 0640},
+		},
+		{
+			name:       "directory without recursive",
+			op:         func(dir string) *Ownership { return &Ownership{Path: dir, Mode: 0755} },
+			wantStatus: StatusChanged,
+			wantModes:  map[string]fs.FileMode{".": 0755, "a.conf": 0600, "sub": 0700},
+		},
+		{
+			name:       "current owner and group",
+			op:         func(dir string) *Ownership { return &Ownership{Path: dir, Owner: uid, Group: gid, Recursive: true} },
+			wantStatus: StatusOk,
+			wantModes:  map[string]fs.FileMode{".": 0700, "a.conf": 0600},
+		},
+		{
+			name:       "nothing to apply",
+			op:         func(dir string) *Ownership { return &Ownership{Path: dir} },
+			wantStatus: StatusOk,
+			wantModes:  map[string]fs.FileMode{".": 0700},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := ownershipTree(t)
+			res, err := tt.op(dir).Execute(context.Background())
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if res.Status != tt.wantStatus {
+				t.Errorf("Execute() status = %s, want %s (%s)", res.Status, tt.wantStatus, res.Reason)
+			}
+			for p, want := range tt.wantModes {
+				fi, err := os.Stat(filepath.Join(dir, p))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Mode().Perm() != want {
+					t.Errorf("Execute() mode of %s = %o, want %o", p, fi.Mode().Perm(), want)
+				}
+			}
+			// applying the same ownership again finds nothing to change
+			if res, err := tt.op(dir).Execute(context.Background()); err != nil || res.Status != StatusOk {
+				t.Errorf("Execute() second run = %v, %v, want ok", res, err)
+			}
+		})
+	}
+}
+
+func TestOwnershipDryRun(t *testing.T) {
+	dir := ownershipTree(t)
+	SetDryRun(true)
+	defer SetDryRun(false)
+	res, err := (&Ownership{Path: dir, Mode: 0644, Recursive: true}).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if res.Status != StatusChanged || res.Reason != "would change ownership on 4 path(s)" {
+		t.Errorf("Execute() = %s %q, want 4 paths planned", res.Status, res.Reason)
+	}
+	if fi, _ := os.Stat(filepath.Join(dir, "a.conf")); fi.Mode().Perm() != 0600 {
+		t.Errorf("Execute() changed the mode during a dry run")
+	}
+}
+
+func TestOwnershipErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		op   *Ownership
+	}{
+		{name: "no path", op: &Ownership{Mode: 0644}},
+		{name: "missing path", op: &Ownership{Path: filepath.Join(t.TempDir(), "missing"), Mode: 0644}},
+		{name: "unknown owner", op: &Ownership{Path: t.TempDir(), Owner: "bruce-no-such-user"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.op.Execute(context.Background()); err == nil {
+				t.Errorf("Execute() expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package operators
+
+import (
+	"bruce/loader"
+	"bruce/system"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	MustRegister("repoName", func() Operator { return &PackageRepo{} })
+}
+
+// PackageRepo installs an apt / dnf / yum repository definition and refreshes the package metadata when it changed.
+type PackageRepo struct {
+	Name       string `yaml:"repoName"`
+	Location   string `yaml:"repoLocation"`
+	RepoType   string `yaml:"repoType"`
+	Key        string `yaml:"repoKey"`
+	Dist       string `yaml:"repoDist"`
+	Components string `yaml:"repoComponents"`
+	OsLimits   string `yaml:"osLimits"`
+	OnlyIf     string `yaml:"onlyIf"`
+	NotIf      string `yaml:"notIf"`
+}
+
+// repoRoot is the root the repository definitions and keyrings are written under, tests point it at a temporary
+// directory.
+var repoRoot = "/"
+
+// Setup renders the repository settings, apt repositories without a repoDist use the codename of the os release.
+func (r *PackageRepo) Setup(ctx context.Context) error {
+	r.Name = RenderEnvString(ctx, r.Name)
+	r.Location = RenderEnvString(ctx, r.Location)
+	r.Key = RenderEnvString(ctx, r.Key)
+	if r.RepoType == "" {
+		r.RepoType = system.Get().PackageHandler
+	}
+	if r.Dist == "" {
+		r.Dist = system.Get().OsName
+	}
+	if r.Dist == "" && r.RepoType == "apt" {
+		return fmt.Errorf("repo %s: repoDist is required as the os release has no codename", r.Name)
+	}
+	if r.Components == "" {
+		r.Components = "main"
+	}
+	return nil
+}
+
+// Execute writes the repository definition for the configured repo type.
+func (r *PackageRepo) Execute(ctx context.Context) (*Result, error) {
+	r = runCopy(r)
+	if err := r.Setup(ctx); err != nil {
+		return nil, err
+	}
+	if !system.Get().CanExecOnOs(r.OsLimits) {
+		log.Ctx(ctx).Info().Str("repo", r.Name).Msgf("skipped due to os limit: %s", r.OsLimits)
+		return Skipped("os limit: %s", r.OsLimits), nil
+	}
+	if res := checkConditions(ctx, r.OnlyIf, r.NotIf); res != nil {
+		return res, nil
+	}
+	if len(r.Name) == 0 || len(r.Location) == 0 {
+		return nil, fmt.Errorf("repoName and repoLocation are required")
+	}
+	log.Ctx(ctx).Info().Msgf("repo: %s (%s) => %s", r.Name, r.RepoType, r.Location)
+	var files []string
+	var err error
+	switch r.RepoType {
+	case "apt":
+		files, err = r.installApt(ctx, filepath.Join(repoRoot, "etc/apt/sources.list.d", r.Name+".list"))
+	case "dnf", "yum":
+		files, err = r.installRpm(ctx, filepath.Join(repoRoot, "etc/yum.repos.d", r.Name+".repo"))
+	default:
+		return nil, fmt.Errorf("unsupported repo type: %q", r.RepoType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		log.Ctx(ctx).Info().Msgf("repo %s is up to date", r.Name)
+		return Ok("repo %s is up to date", r.Name), nil
+	}
+	refresh := fmt.Sprintf("%s makecache -y", r.RepoType)
+	if r.RepoType == "apt" {
+		refresh = "apt-get update"
+	}
+	log.Ctx(ctx).Info().Msgf("repo %s changed, refreshing package metadata", r.Name)
+	if err := runPackageCmd(ctx, refresh); err != nil {
+		return nil, err
+	}
+	return Changed("repo %s updated", r.Name).WithFiles(files...).WithResources("repo", r.Name), nil
+}
+
+// installApt writes the keyring and sources list of an apt repository, returning the files it changed.
+func (r *PackageRepo) installApt(ctx context.Context, repoFile string) ([]string, error) {
+	var changed []string
+	var opts []string
+	if out, err := runCommand(ctx, "dpkg --print-architecture"); err == nil {
+		opts = append(opts, "arch="+strings.TrimSpace(out))
+	}
+	if len(r.Key) > 0 {
+		d, _, err := loader.GetRemoteDataContext(ctx, r.Key)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("could not read repo key: %s", r.Key)
+			return nil, err
+		}
+		// apt accepts ascii armored keys as long as the keyring ends in .asc
+		keyring := filepath.Join(repoRoot, "etc/apt/keyrings", r.Name+".gpg")
+		if bytes.Contains(d, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+			keyring = filepath.Join(repoRoot, "etc/apt/keyrings", r.Name+".asc")
+		}
+		kc, err := writeIfChanged(ctx, keyring, d, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if kc {
+			changed = append(changed, keyring)
+		}
+		opts = append(opts, "signed-by="+keyring)
+	}
+	line := "deb "
+	if len(opts) > 0 {
+		line += fmt.Sprintf("[%s] ", strings.Join(opts, " "))
+	}
+	line += fmt.Sprintf("%s %s %s\n", r.Location, r.Dist, r.Components)
+	lc, err := writeIfChanged(ctx, repoFile, []byte(line), 0644)
+	if err != nil {
+		return nil, err
+	}
+	if lc {
+		changed = append(changed, repoFile)
+	}
+	return changed, nil
+}
+
+// installRpm writes the repo file of a dnf / yum repository and imports its key, returning the files it changed.
+func (r *PackageRepo) installRpm(ctx context.Context, repoFile string) ([]string, error) {
+	var content []byte
+	if strings.HasSuffix(r.Location, ".repo") {
+		d, _, err := loader.GetRemoteDataContext(ctx, r.Location)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("could not read repo file: %s", r.Location)
+			return nil, err
+		}
+		content = d
+	} else {
+		b := &bytes.Buffer{}
+		fmt.Fprintf(b, "[%s]\nname=%s\nbaseurl=%s\nenabled=1\n", r.Name, r.Name, r.Location)
+		if len(r.Key) > 0 {
+			fmt.Fprintf(b, "gpgcheck=1\ngpgkey=%s\n", r.Key)
+		} else {
+			b.WriteString("gpgcheck=0\n")
+		}
+		content = b.Bytes()
+	}
+	changed, err := writeIfChanged(ctx, repoFile, content, 0644)
+	if err != nil || !changed {
+		return nil, err
+	}
+	if len(r.Key) > 0 {
+		log.Ctx(ctx).Info().Msgf("importing repo key: %s", r.Key)
+		if err := runPackageCmd(ctx, "rpm --import", r.Key); err != nil {
+			return nil, err
+		}
+	}
+	return []string{repoFile}, nil
+}
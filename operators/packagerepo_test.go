@@ -0,0 +1,145 @@
+package operators
+
+import (
+	"bruce/system"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPackageRepoSources(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     string
+		codename    string
+		op          *PackageRepo
+		outputs     map[string]string
+		wantFile    string
+		wantContent string
+		wantRan     []string
+		wantErr     bool
+	}{
+		{
+			name:        "apt with the os codename",
+			handler:     "apt",
+			codename:    "bookworm",
+			op:          &PackageRepo{Name: "nginx", Location: "https://nginx.org/packages/debian"},
+			outputs:     map[string]string{"dpkg --print-architecture": "amd64\n"},
+			wantFile:    "etc/apt/sources.list.d/nginx.list",
+			wantContent: "deb [arch=amd64] https://nginx.org/packages/debian bookworm main\n",
+			wantRan:     []string{"apt-get update"},
+		},
+		{
+			name:        "apt with dist and components",
+			handler:     "apt",
+			op:          &PackageRepo{Name: "pg", Location: "https://apt.postgresql.org/pub/repos/apt", Dist: "jammy-pgdg", Components: "main 16"},
+			wantFile:    "etc/apt/sources.list.d/pg.list",
+			wantContent: "deb https://apt.postgresql.org/pub/repos/apt jammy-pgdg main 16\n",
+			wantRan:     []string{"apt-get update"},
+		},
+		{
+			name:    "apt without a dist",
+			handler: "apt",
+			op:      &PackageRepo{Name: "nginx", Location: "https://nginx.org/packages/debian"},
+			wantErr: true,
+		},
+		{
+			name:        "dnf",
+			handler:     "dnf",
+			op:          &PackageRepo{Name: "nginx", Location: "https://nginx.org/packages/centos/9/x86_64/"},
+			wantFile:    "etc/yum.repos.d/nginx.repo",
+			wantContent: "[nginx]\nname=nginx\nbaseurl=https://nginx.org/packages/centos/9/x86_64/\nenabled=1\ngpgcheck=0\n",
+			wantRan:     []string{"dnf makecache -y"},
+		},
+		{
+			name:        "yum with a key",
+			handler:     "apt",
+			op:          &PackageRepo{Name: "nginx", RepoType: "yum", Location: "https://nginx.org/packages/centos/7/x86_64/", Key: "https://nginx.org/keys/nginx_signing.key"},
+			wantFile:    "etc/yum.repos.d/nginx.repo",
+			wantContent: "[nginx]\nname=nginx\nbaseurl=https://nginx.org/packages/centos/7/x86_64/\nenabled=1\ngpgcheck=1\ngpgkey=https://nginx.org/keys/nginx_signing.key\n",
+			wantRan:     []string{"rpm --import https://nginx.org/keys/nginx_signing.key", "yum makecache -y"},
+		},
+		{
+			name:    "unsupported repo type",
+			handler: "apk",
+			op:      &PackageRepo{Name: "edge", Location: "https://dl-cdn.alpinelinux.org/alpine/edge/main"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeRunner{outputs: tt.outputs}
+			useRunner(t, f, func(s *system.SystemInfo) { s.PackageHandler, s.OsName = tt.handler, tt.codename })
+			root := useRepoRoot(t)
+			res, err := tt.op.Execute(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			repoFile := filepath.Join(root, tt.wantFile)
+			if d, err := os.ReadFile(repoFile); err != nil || string(d) != tt.wantContent {
+				t.Errorf("Execute() wrote %q (%v), want %q", d, err, tt.wantContent)
+			}
+			if res.Status != StatusChanged || !reflect.DeepEqual(res.Files, []string{repoFile}) {
+				t.Errorf("Execute() = %s with files %v, want the repo file changed", res.Status, res.Files)
+			}
+			if !reflect.DeepEqual(f.ran, tt.wantRan) {
+				t.Errorf("Execute() ran %q, want %q", f.ran, tt.wantRan)
+			}
+			// the definition is unchanged on the next run so the metadata is not refreshed again
+			f.ran = nil
+			if res, err := tt.op.Execute(context.Background()); err != nil || res.Status != StatusOk || len(f.ran) != 0 {
+				t.Errorf("Execute() second run = %v, %v and ran %q, want ok", res, err, f.ran)
+			}
+		})
+	}
+}
+
+// useRepoRoot writes the repository definitions of the test under a temporary directory.
+func useRepoRoot(t *testing.T) string {
+	t.Helper()
+	root, old := t.TempDir(), repoRoot
+	repoRoot = root
+	t.Cleanup(func() { repoRoot = old })
+	return root
+}
+
+func TestPackageRepoKeyring(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		wantKeyring string
+	}{
+		{name: "armored key", key: "-----BEGIN PGP PUBLIC KEY BLOCK-----\nabc\n-----END PGP PUBLIC KEY BLOCK-----\n", wantKeyring: "etc/apt/keyrings/nginx.asc"},
+		{name: "binary key", key: "\x99\x01\x0d\x04", wantKeyring: "etc/apt/keyrings/nginx.gpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useRunner(t, &fakeRunner{}, func(s *system.SystemInfo) { s.PackageHandler, s.OsName = "apt", "bookworm" })
+			root := useRepoRoot(t)
+			keyFile := filepath.Join(t.TempDir(), "nginx.key")
+			if err := os.WriteFile(keyFile, []byte(tt.key), 0644); err != nil {
+				t.Fatal(err)
+			}
+			res, err := (&PackageRepo{Name: "nginx", Location: "https://nginx.org/packages/debian", Key: keyFile}).Execute(context.Background())
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			keyring, repoFile := filepath.Join(root, tt.wantKeyring), filepath.Join(root, "etc/apt/sources.list.d/nginx.list")
+			if d, err := os.ReadFile(keyring); err != nil || string(d) != tt.key {
+				t.Errorf("Execute() keyring = %q (%v), want the key", d, err)
+			}
+			if !reflect.DeepEqual(res.Files, []string{keyring, repoFile}) {
+				t.Errorf("Execute() files = %v, want the keyring and the sources list", res.Files)
+			}
+			want := "deb [signed-by=" + keyring + "] https://nginx.org/packages/debian bookworm main\n"
+			if d, _ := os.ReadFile(repoFile); string(d) != want {
+				t.Errorf("Execute() sources = %q, want %q", d, want)
+			}
+		})
+	}
+}
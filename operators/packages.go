@@ -0,0 +1,293 @@
+package operators
+
+import (
+	"bruce/exe"
+	"bruce/system"
+	"context"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+func init() {
+	MustRegister("packageList", func() Operator { return &Packages{} })
+}
+
+// Packages installs, removes, upgrades or pins os packages with the locally supported package handler.
+// Each package name is passed through GetValueForOSHandler, eg: nginx|apt=nginx-full
+type Packages struct {
+	PackageList []string `yaml:"packageList"`
+	State       string   `yaml:"state"`
+	Version     string   `yaml:"version"`
+	Hold        bool     `yaml:"hold"`
+	UpdateCache bool     `yaml:"updateCache"`
+	OsLimits    string   `yaml:"osLimits"`
+	OnlyIf      string   `yaml:"onlyIf"`
+	NotIf       string   `yaml:"notIf"`
+}
+
+// packageManager holds the commands used for each supported package handler, pin and hold are empty when unsupported.
+type packageManager struct {
+	refresh   string
+	install   string
+	remove    string
+	upgrade   string
+	hold      string
+	pin       string
+	installed string
+	version   string
+}
+
+var packageManagers = map[string]packageManager{
+	"apt": {
+		refresh:   "apt-get update",
+		install:   "apt-get install -y",
+		remove:    "apt-get remove -y",
+		upgrade:   "apt-get install -y --only-upgrade",
+		hold:      "apt-mark hold",
+		pin:       "%s=%s",
+		installed: "dpkg-query -W -f=${Status} %s",
+		version:   "dpkg-query -W -f=${Version} %s",
+	},
+	"dnf": {
+		refresh:   "dnf makecache -y",
+		install:   "dnf install -y",
+		remove:    "dnf remove -y",
+		upgrade:   "dnf upgrade -y",
+		hold:      "dnf versionlock add",
+		pin:       "%s-%s",
+		installed: "rpm -q %s",
+		version:   "rpm -q --qf %%{VERSION}-%%{RELEASE} %s",
+	},
+	"yum": {
+		refresh:   "yum makecache -y",
+		install:   "yum install -y",
+		remove:    "yum remove -y",
+		upgrade:   "yum update -y",
+		hold:      "yum versionlock add",
+		pin:       "%s-%s",
+		installed: "rpm -q %s",
+		version:   "rpm -q --qf %%{VERSION}-%%{RELEASE} %s",
+	},
+	"apk": {
+		refresh:   "apk update",
+		install:   "apk add",
+		remove:    "apk del",
+		upgrade:   "apk add --upgrade",
+		pin:       "%s=%s",
+		installed: "apk info -e %s",
+		version:   "apk info -v %s",
+	},
+	"pacman": {
+		refresh:   "pacman -Sy --noconfirm",
+		install:   "pacman -S --noconfirm --needed",
+		remove:    "pacman -R --noconfirm",
+		upgrade:   "pacman -S --noconfirm",
+		installed: "pacman -Q %s",
+		version:   "pacman -Q %s",
+	},
+	"zypper": {
+		refresh:   "zypper --non-interactive refresh",
+		install:   "zypper --non-interactive install",
+		remove:    "zypper --non-interactive remove",
+		upgrade:   "zypper --non-interactive update",
+		hold:      "zypper --non-interactive addlock",
+		pin:       "%s=%s",
+		installed: "rpm -q %s",
+		version:   "rpm -q --qf %%{VERSION}-%%{RELEASE} %s",
+	},
+}
+
+func (p *Packages) Setup(ctx context.Context) {
+	if p.State == "" {
+		p.State = "present"
+	}
+	p.State = strings.ToLower(p.State)
+}
+
+// Execute applies the requested package state.
+func (p *Packages) Execute(ctx context.Context) (*Result, error) {
+	p.Setup(ctx)
+	if !system.Get().CanExecOnOs(p.OsLimits) {
+		log.Ctx(ctx).Info().Str("packages", strings.Join(p.PackageList, " ")).Msgf("skipped due to os limit: %s", p.OsLimits)
+		return Skipped("os limit: %s", p.OsLimits), nil
+	}
+	if r := checkConditions(ctx, p.OnlyIf, p.NotIf); r != nil {
+		return r, nil
+	}
+	handler := system.Get().PackageHandler
+	pm, ok := packageManagers[handler]
+	if !ok {
+		return nil, fmt.Errorf("unsupported package handler: %q", handler)
+	}
+	var pkgs []string
+	for _, pkg := range p.PackageList {
+		if name := GetValueForOSHandler(pkg); name != "" {
+			pkgs = append(pkgs, name)
+		}
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no packages to manage for handler: %s", handler)
+	}
+	if p.UpdateCache {
+		log.Ctx(ctx).Info().Msgf("packages: refreshing %s cache", handler)
+		if err := runPackageCmd(ctx, pm.refresh); err != nil {
+			return nil, err
+		}
+	}
+
+	var changes, changed []string
+	switch p.State {
+	case "present", "installed":
+		var pending []string
+		for _, pkg := range pkgs {
+			if !pm.isInstalled(ctx, pkg) {
+				pending = append(pending, pkg)
+			}
+		}
+		if len(pending) > 0 {
+			log.Ctx(ctx).Info().Msgf("packages install: %s", strings.Join(pending, " "))
+			if err := runPackageCmd(ctx, pm.install, pending...); err != nil {
+				return nil, err
+			}
+			changes = append(changes, "installed "+strings.Join(pending, " "))
+			changed = append(changed, pending...)
+		}
+	case "absent", "removed":
+		var pending []string
+		for _, pkg := range pkgs {
+			if pm.isInstalled(ctx, pkg) {
+				pending = append(pending, pkg)
+			}
+		}
+		if len(pending) > 0 {
+			log.Ctx(ctx).Info().Msgf("packages remove: %s", strings.Join(pending, " "))
+			if err := runPackageCmd(ctx, pm.remove, pending...); err != nil {
+				return nil, err
+			}
+			changes = append(changes, "removed "+strings.Join(pending, " "))
+			changed = append(changed, pending...)
+		}
+	case "latest":
+		var missing, upgrades []string
+		versions := make(map[string]string)
+		for _, pkg := range pkgs {
+			if pm.isInstalled(ctx, pkg) {
+				upgrades = append(upgrades, pkg)
+				versions[pkg] = pm.installedVersion(ctx, pkg)
+			} else {
+				missing = append(missing, pkg)
+			}
+		}
+		if len(missing) > 0 {
+			log.Ctx(ctx).Info().Msgf("packages install: %s", strings.Join(missing, " "))
+			if err := runPackageCmd(ctx, pm.install, missing...); err != nil {
+				return nil, err
+			}
+			changes = append(changes, "installed "+strings.Join(missing, " "))
+			changed = append(changed, missing...)
+		}
+		if len(upgrades) > 0 {
+			log.Ctx(ctx).Info().Msgf("packages upgrade: %s", strings.Join(upgrades, " "))
+			if err := runPackageCmd(ctx, pm.upgrade, upgrades...); err != nil {
+				return nil, err
+			}
+			var upgraded []string
+			for _, pkg := range upgrades {
+				if DryRun() || pm.installedVersion(ctx, pkg) != versions[pkg] {
+					upgraded = append(upgraded, pkg)
+				}
+			}
+			if len(upgraded) > 0 {
+				changes = append(changes, "upgraded "+strings.Join(upgraded, " "))
+				changed = append(changed, upgraded...)
+			}
+		}
+	case "pinned":
+		version := GetValueForOSHandler(p.Version)
+		if version == "" {
+			return nil, fmt.Errorf("pinned package state requires a version")
+		}
+		if pm.pin == "" {
+			return nil, fmt.Errorf("pinned package versions are not supported by %s", handler)
+		}
+		var pending []string
+		for _, pkg := range pkgs {
+			if !strings.HasPrefix(pm.installedVersion(ctx, pkg), version) {
+				pending = append(pending, fmt.Sprintf(pm.pin, pkg, version))
+				changed = append(changed, pkg)
+			}
+		}
+		if len(pending) > 0 {
+			log.Ctx(ctx).Info().Msgf("packages install: %s", strings.Join(pending, " "))
+			if err := runPackageCmd(ctx, pm.install, pending...); err != nil {
+				return nil, err
+			}
+			changes = append(changes, "pinned "+strings.Join(pending, " "))
+		}
+	default:
+		return nil, fmt.Errorf("unknown package state: %s", p.State)
+	}
+
+	if p.Hold && p.State != "absent" && p.State != "removed" {
+		if pm.hold == "" {
+			return nil, fmt.Errorf("package holds are not supported by %s", handler)
+		}
+		log.Ctx(ctx).Info().Msgf("packages hold: %s", strings.Join(pkgs, " "))
+		if err := runPackageCmd(ctx, pm.hold, pkgs...); err != nil {
+			return nil, err
+		}
+	}
+	if len(changes) == 0 {
+		log.Ctx(ctx).Info().Msgf("packages already %s: %s", p.State, strings.Join(pkgs, " "))
+		return Ok("packages already %s: %s", p.State, strings.Join(pkgs, " ")), nil
+	}
+	return Changed("packages %s", strings.Join(changes, ", ")).WithResources("package", changed...), nil
+}
+
+// runCommand runs a system command and returns its combined output, the package, repo and service operators use it so
+// tests can replace it and run the operators without root access.
+var runCommand = func(ctx context.Context, c string) (string, error) {
+	pc := exe.RunContext(ctx, c, "")
+	return pc.Get(), pc.GetErr()
+}
+
+func (pm packageManager) isInstalled(ctx context.Context, pkg string) bool {
+	out, err := runCommand(ctx, fmt.Sprintf(pm.installed, pkg))
+	if err != nil {
+		return false
+	}
+	// dpkg keeps removed packages around with a deinstall status
+	if strings.HasPrefix(pm.installed, "dpkg-query") {
+		return strings.Contains(out, "install ok installed")
+	}
+	return true
+}
+
+func (pm packageManager) installedVersion(ctx context.Context, pkg string) string {
+	out, err := runCommand(ctx, fmt.Sprintf(pm.version, pkg))
+	if err != nil {
+		return ""
+	}
+	v := strings.TrimSpace(out)
+	// apk and pacman return the package name along with the version
+	v = strings.TrimPrefix(v, pkg+" ")
+	v = strings.TrimPrefix(v, pkg+"-")
+	return v
+}
+
+func runPackageCmd(ctx context.Context, cmd string, pkgs ...string) error {
+	c := strings.TrimSpace(cmd + " " + strings.Join(pkgs, " "))
+	if DryRun() {
+		planned(ctx, "run: %s", c)
+		return nil
+	}
+	log.Ctx(ctx).Debug().Msgf("package cmd: %s", c)
+	out, err := runCommand(ctx, c)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg(out)
+		return fmt.Errorf("%s: %w", c, err)
+	}
+	log.Ctx(ctx).Debug().Msgf("Output: %s", out)
+	return nil
+}
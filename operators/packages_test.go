@@ -0,0 +1,182 @@
+package operators
+
+import (
+	"bruce/system"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRunner replaces runCommand, queries answer from outputs (failing when missing) and every other command succeeds.
+type fakeRunner struct {
+	outputs map[string]string
+	fail    map[string]bool
+	ran     []string
+}
+
+// queryPrefixes are the read only commands the operators run to inspect the system.
+var queryPrefixes = []string{"dpkg-query", "dpkg --print-architecture", "rpm -q", "apk info", "pacman -Q", "systemctl is-"}
+
+func (f *fakeRunner) run(ctx context.Context, c string) (string, error) {
+	if f.fail[c] {
+		return "failed", errors.New("exit status 1")
+	}
+	for _, p := range queryPrefixes {
+		if strings.HasPrefix(c, p) {
+			if out, ok := f.outputs[c]; ok {
+				return out, nil
+			}
+			return "", errors.New("exit status 1")
+		}
+	}
+	f.ran = append(f.ran, c)
+	return f.outputs[c], nil
+}
+
+// useRunner replaces the command runner and the system info for the duration of the test.
+func useRunner(t *testing.T, f *fakeRunner, configure func(s *system.SystemInfo)) {
+	t.Helper()
+	run, s := runCommand, *system.Get()
+	runCommand = f.run
+	configure(system.Get())
+	t.Cleanup(func() {
+		runCommand = run
+		*system.Get() = s
+	})
+}
+
+func TestPackagesExecute(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    string
+		op         *Packages
+		outputs    map[string]string
+		wantStatus Status
+		wantRan    []string
+		wantErr    bool
+	}{
+		{
+			name:       "install missing apt packages",
+			handler:    "apt",
+			op:         &Packages{PackageList: []string{"curl", "nginx|apt=nginx-full"}},
+			outputs:    map[string]string{"dpkg-query -W -f=${Status} curl": "install ok installed"},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"apt-get install -y nginx-full"},
+		},
+		{
+			name:       "removed apt packages are not installed",
+			handler:    "apt",
+			op:         &Packages{PackageList: []string{"curl"}, UpdateCache: true},
+			outputs:    map[string]string{"dpkg-query -W -f=${Status} curl": "deinstall ok config-files"},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"apt-get update", "apt-get install -y curl"},
+		},
+		{
+			name:       "already installed",
+			handler:    "dnf",
+			op:         &Packages{PackageList: []string{"curl"}, State: "Installed"},
+			outputs:    map[string]string{"rpm -q curl": "curl-8.0"},
+			wantStatus: StatusOk,
+		},
+		{
+			name:       "remove installed packages",
+			handler:    "yum",
+			op:         &Packages{PackageList: []string{"curl", "wget"}, State: "absent"},
+			outputs:    map[string]string{"rpm -q wget": "wget-1.21"},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"yum remove -y wget"},
+		},
+		{
+			name:    "latest installs and upgrades",
+			handler: "apk",
+			op:      &Packages{PackageList: []string{"curl", "wget"}, State: "latest"},
+			outputs: map[string]string{
+				"apk info -e curl": "curl",
+				"apk info -v curl": "curl-8.0",
+			},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"apk add wget", "apk add --upgrade curl"},
+		},
+		{
+			name:       "pinned version",
+			handler:    "apt",
+			op:         &Packages{PackageList: []string{"nginx"}, State: "pinned", Version: "1.24", Hold: true},
+			outputs:    map[string]string{"dpkg-query -W -f=${Version} nginx": "1.22.1"},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"apt-get install -y nginx=1.24", "apt-mark hold nginx"},
+		},
+		{
+			name:       "pinned version already installed",
+			handler:    "dnf",
+			op:         &Packages{PackageList: []string{"nginx"}, State: "pinned", Version: "1.24"},
+			outputs:    map[string]string{"rpm -q --qf %{VERSION}-%{RELEASE} nginx": "1.24.0-1.el9"},
+			wantStatus: StatusOk,
+		},
+		{
+			name:    "pinning unsupported",
+			handler: "pacman",
+			op:      &Packages{PackageList: []string{"nginx"}, State: "pinned", Version: "1.24"},
+			wantErr: true,
+		},
+		{
+			name:    "hold unsupported",
+			handler: "apk",
+			op:      &Packages{PackageList: []string{"nginx"}, Hold: true},
+			wantRan: []string{"apk add nginx"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown state",
+			handler: "apt",
+			op:      &Packages{PackageList: []string{"nginx"}, State: "purged"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported handler",
+			handler: "brew",
+			op:      &Packages{PackageList: []string{"nginx"}},
+			wantErr: true,
+		},
+		{
+			name:    "no package for the handler",
+			handler: "dnf",
+			op:      &Packages{PackageList: []string{"|apt=nginx-full"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeRunner{outputs: tt.outputs}
+			useRunner(t, f, func(s *system.SystemInfo) { s.PackageHandler = tt.handler })
+			res, err := tt.op.Execute(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && res.Status != tt.wantStatus {
+				t.Errorf("Execute() status = %s, want %s (%s)", res.Status, tt.wantStatus, res.Reason)
+			}
+			if !reflect.DeepEqual(f.ran, tt.wantRan) {
+				t.Errorf("Execute() ran %q, want %q", f.ran, tt.wantRan)
+			}
+		})
+	}
+}
+
+func TestPackagesDryRun(t *testing.T) {
+	f := &fakeRunner{}
+	useRunner(t, f, func(s *system.SystemInfo) { s.PackageHandler = "apt" })
+	SetDryRun(true)
+	defer SetDryRun(false)
+	res, err := (&Packages{PackageList: []string{"nginx"}}).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if res.Status != StatusChanged || len(f.ran) != 0 {
+		t.Errorf("Execute() = %s and ran %q, want a planned install only", res.Status, f.ran)
+	}
+	if !reflect.DeepEqual(res.Resources, []string{"package:nginx"}) {
+		t.Errorf("Execute() resources = %v, want package:nginx", res.Resources)
+	}
+}
@@ -1,12 +1,16 @@
 package operators
 
 import (
-	"bruce/exe"
 	"bruce/loader"
+	"context"
 	"github.com/rs/zerolog/log"
 	"os"
 )
 
+func init() {
+	MustRegister("copyRecursive", func() Operator { return &RecursiveCopy{} })
+}
+
 type RecursiveCopy struct {
 	Src           string   `yaml:"copyRecursive"`
 	Dest          string   `yaml:"dest"`
@@ -18,13 +22,13 @@ type RecursiveCopy struct {
 	NotIf         string   `yaml:"notIf"`
 }
 
-func (c *RecursiveCopy) Setup() {
-	c.Dest = RenderEnvString(c.Dest)
+func (c *RecursiveCopy) Setup(ctx context.Context) {
+	c.Dest = RenderEnvString(ctx, c.Dest)
 	// Check if parent directory exists and create it if it doesn't
-	if _, err := os.Stat(c.Dest); os.IsNotExist(err) {
+	if _, err := os.Stat(c.Dest); os.IsNotExist(err) && !DryRun() {
 		err = os.MkdirAll(c.Dest, 0755)
 		if err != nil {
-			log.Error().Err(err).Msg("failed to create parent directory for recursive copy")
+			log.Ctx(ctx).Error().Err(err).Msg("failed to create parent directory for recursive copy")
 		}
 	}
 	if c.MaxConcurrent == 0 {
@@ -32,29 +36,22 @@ func (c *RecursiveCopy) Setup() {
 	}
 }
 
-func (c *RecursiveCopy) Execute() error {
-	c.Setup()
-	if len(c.OnlyIf) > 0 {
-		pc := exe.Run(c.OnlyIf, "")
-		if pc.Failed() || len(pc.Get()) == 0 {
-			log.Info().Msgf("skipping on (onlyIf): %s", c.OnlyIf)
-			return nil
-		}
+func (c *RecursiveCopy) Execute(ctx context.Context) (*Result, error) {
+	c = runCopy(c)
+	c.Setup(ctx)
+	if r := checkConditions(ctx, c.OnlyIf, c.NotIf); r != nil {
+		return r, nil
 	}
-	// if notIf is set, check if it's return value is empty / false
-	if len(c.NotIf) > 0 {
-		pc := exe.Run(c.NotIf, "")
-		if !pc.Failed() || len(pc.Get()) > 0 {
-			log.Info().Msgf("skipping on (notIf): %s", c.NotIf)
-			return nil
-		}
+	if DryRun() {
+		planned(ctx, "copy recursively: %s => %s", c.Src, c.Dest)
+		return Changed("would copy recursively: %s => %s", c.Src, c.Dest).WithFiles(c.Dest), nil
 	}
-	log.Info().Msgf("rcopy (%d files at a time) with a maxDepth of: %d", c.MaxConcurrent, c.MaxDepth)
-	log.Info().Msgf("  %s => %s", c.Src, c.Dest)
-	err := loader.RecursiveCopy(c.Src, c.Dest, c.Dest, true, c.Ignores, c.FlatCopy, c.MaxDepth, c.MaxConcurrent)
+	log.Ctx(ctx).Info().Msgf("rcopy (%d files at a time) with a maxDepth of: %d", c.MaxConcurrent, c.MaxDepth)
+	log.Ctx(ctx).Info().Msgf("  %s => %s", c.Src, c.Dest)
+	err := loader.RecursiveCopyContext(ctx, c.Src, c.Dest, c.Dest, true, c.Ignores, c.FlatCopy, c.MaxDepth, c.MaxConcurrent)
 	if err != nil {
-		log.Error().Err(err).Msg("could not copy file")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("could not copy file")
+		return nil, err
 	}
-	return nil
+	return Changed("copied recursively: %s => %s", c.Src, c.Dest).WithFiles(c.Dest), nil
 }
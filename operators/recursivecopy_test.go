@@ -0,0 +1,60 @@
+package operators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecursiveCopyExecute(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/":
+			fmt.Fprint(w, `<a href="a.conf">a.conf</a><a href="sub/">sub/</a>`)
+		case "/files/sub/":
+			fmt.Fprint(w, `<a href="b.conf">b.conf</a>`)
+		case "/slow/":
+			// the listing never completes before the step times out
+			<-r.Context().Done()
+		default:
+			fmt.Fprint(w, filepath.Base(r.URL.Path))
+		}
+	}))
+	defer srv.Close()
+	tests := []struct {
+		name      string
+		src       string
+		timeout   time.Duration
+		wantFiles []string
+		wantErr   error
+	}{
+		{name: "copies the tree", src: srv.URL + "/files/", timeout: 5 * time.Second, wantFiles: []string{"a.conf", "sub/b.conf"}},
+		{name: "step timeout", src: srv.URL + "/slow/", timeout: 50 * time.Millisecond, wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "dest")
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			start := time.Now()
+			_, err := (&RecursiveCopy{Src: tt.src, Dest: dest}).Execute(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+			if time.Since(start) > time.Second {
+				t.Errorf("Execute() took %s, want it bound by the step timeout", time.Since(start))
+			}
+			for _, f := range tt.wantFiles {
+				if d, err := os.ReadFile(filepath.Join(dest, f)); err != nil || string(d) != filepath.Base(f) {
+					t.Errorf("Execute() %s = %q (%v), want it copied", f, d, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package operators
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Constructor returns a new zero valued operator that a manifest step can be decoded into.
+type Constructor func() Operator
+
+type registration struct {
+	key     string
+	primary string
+	typ     reflect.Type
+	create  Constructor
+	fields  map[string]bool
+}
+
+var (
+	registry     = make(map[string]*registration)
+	registryLock = new(sync.RWMutex)
+)
+
+// Register associates a discriminating manifest key (eg: cmd, template) with an operator constructor.
+// Any step containing the key will be decoded into the operator returned by the constructor, this also allows
+// programs embedding bruce to provide their own operators. Registering further keys for an operator type makes them
+// aliases of the first key (eg: pidFile for signal), steps matched by an alias report the first key.
+func Register(key string, c Constructor) error {
+	if len(key) == 0 || c == nil {
+		return fmt.Errorf("operator registration requires a key and constructor")
+	}
+	op := c()
+	if op == nil {
+		return fmt.Errorf("operator constructor for %s returned nil", key)
+	}
+	fields := yamlFields(reflect.TypeOf(op))
+	if !fields[key] {
+		return fmt.Errorf("operator key %s is not a field of %T", key, op)
+	}
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[key]; ok {
+		return fmt.Errorf("operator key %s is already registered", key)
+	}
+	r := &registration{key: key, primary: key, typ: reflect.TypeOf(op), create: c, fields: fields}
+	for _, o := range registry {
+		if o.typ == r.typ {
+			r.primary = o.primary
+			break
+		}
+	}
+	registry[key] = r
+	return nil
+}
+
+// MustRegister is the same as Register but panics on failure, intended for use in init functions.
+func MustRegister(key string, c Constructor) {
+	if err := Register(key, c); err != nil {
+		panic(err)
+	}
+}
+
+// Registered returns the sorted list of registered operator keys.
+func Registered() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	keys := make([]string, 0, len(registry))
+	for k := range registry {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Decode finds the registered operator for a manifest step and decodes the step into it.
+// The common keys are fields handled by the caller (eg: name) and are never reported as unknown.
+// It returns the matched operator key along with the operator.
+func Decode(nd *yaml.Node, common ...string) (string, Operator, error) {
+	if nd.Kind != yaml.MappingNode {
+		return "", nil, fmt.Errorf("line %d: step must be a mapping of operator keys", nd.Line)
+	}
+	var keys []string
+	for i := 0; i+1 < len(nd.Content); i += 2 {
+		keys = append(keys, nd.Content[i].Value)
+	}
+
+	registryLock.RLock()
+	var candidates []*registration
+	for _, k := range keys {
+		if r, ok := registry[k]; ok {
+			// aliases of the same operator do not compete with each other
+			r = registry[r.primary]
+			if !containsRegistration(candidates, r) {
+				candidates = append(candidates, r)
+			}
+		}
+	}
+	registryLock.RUnlock()
+
+	if len(candidates) == 0 {
+		return "", nil, fmt.Errorf("no operator matches keys: %s", strings.Join(keys, ", "))
+	}
+	// an operator key may also be a plain field of another operator (eg: cron uses cmd), in which case it
+	// does not compete for the step.
+	var matched []*registration
+	for _, c := range candidates {
+		shadowed := false
+		for _, o := range candidates {
+			if o != c && o.fields[c.key] {
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed {
+			matched = append(matched, c)
+		}
+	}
+	if len(matched) != 1 {
+		var names []string
+		for _, c := range candidates {
+			names = append(names, c.key)
+		}
+		return "", nil, fmt.Errorf("ambiguous step, multiple operator keys found: %s", strings.Join(names, ", "))
+	}
+	r := matched[0]
+
+	var unknown []string
+	for _, k := range keys {
+		if !r.fields[k] && !contains(common, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		return "", nil, fmt.Errorf("unknown keys for %s operator: %s", r.key, strings.Join(unknown, ", "))
+	}
+	op := r.create()
+	if err := nd.Decode(op); err != nil {
+		return "", nil, fmt.Errorf("%s operator: %w", r.key, err)
+	}
+	return r.key, op, nil
+}
+
+// yamlFields returns the set of yaml keys a struct type will decode.
+func yamlFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for k := range yamlFields(f.Type) {
+				fields[k] = true
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = true
+	}
+	return fields
+}
+
+func containsRegistration(list []*registration, r *registration) bool {
+	for _, v := range list {
+		if v == r {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
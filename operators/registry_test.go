@@ -0,0 +1,92 @@
+package operators
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    string
+		wantKey string
+		wantErr bool
+	}{
+		{
+			name:    "command",
+			step:    "cmd: echo hello\nosLimits: all",
+			wantKey: "cmd",
+		},
+		{
+			name:    "cron uses cmd as a field",
+			step:    "cron: foo\nschedule: \"* * * * *\"\ncmd: echo hello",
+			wantKey: "cron",
+		},
+		{
+			name:    "common step name",
+			step:    "name: say hello\ncmd: echo hello",
+			wantKey: "cmd",
+		},
+		{
+			name:    "schedule is not a command field",
+			step:    "cmd: echo hello\nschedule: \"* * * * *\"",
+			wantErr: true,
+		},
+		{
+			name:    "ambiguous operators",
+			step:    "cmd: echo hello\ncopy: ./foo\ndest: ./bar",
+			wantErr: true,
+		},
+		{
+			name:    "alias key",
+			step:    "pidFile: /var/run/nginx.pid",
+			wantKey: "signal",
+		},
+		{
+			name:    "alias with primary key",
+			step:    "signal: SIGHUP\npidFile: /var/run/nginx.pid",
+			wantKey: "signal",
+		},
+		{
+			name:    "unknown operator",
+			step:    "cmdd: echo hello",
+			wantErr: true,
+		},
+		{
+			name:    "not a mapping",
+			step:    "- cmd: echo hello",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &yaml.Node{}
+			if err := yaml.Unmarshal([]byte(tt.step), doc); err != nil {
+				t.Fatalf("invalid test yaml: %s", err)
+			}
+			key, op, err := Decode(doc.Content[0], "name")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if key != tt.wantKey {
+				t.Errorf("Decode() key = %s, want %s", key, tt.wantKey)
+			}
+			if op == nil {
+				t.Errorf("Decode() returned a nil operator")
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register("cmd", func() Operator { return &Command{} }); err == nil {
+		t.Errorf("Register() expected an error for a duplicate key")
+	}
+	if err := Register("notAField", func() Operator { return &Command{} }); err == nil {
+		t.Errorf("Register() expected an error for a key that is not an operator field")
+	}
+}
@@ -2,12 +2,17 @@ package operators
 
 import (
 	"bruce/rssh"
+	"bruce/vars"
+	"context"
 	"github.com/rs/zerolog/log"
-	"os"
 	"os/user"
 	"strings"
 )
 
+func init() {
+	MustRegister("remoteCmd", func() Operator { return &RemoteExec{} })
+}
+
 type RemoteExec struct {
 	ExecCmd string `yaml:"remoteCmd"`
 	RemHost string `yaml:"host"`
@@ -17,19 +22,24 @@ type RemoteExec struct {
 	NotIf   string `yaml:"notIf"`
 }
 
-func (re *RemoteExec) Setup() {
-	re.ExecCmd = RenderEnvString(re.ExecCmd)
-	re.RemHost = RenderEnvString(re.RemHost)
-	re.OnlyIf = RenderEnvString(re.OnlyIf)
-	re.NotIf = RenderEnvString(re.NotIf)
+func (re *RemoteExec) Setup(ctx context.Context) {
+	re.ExecCmd = RenderEnvString(ctx, re.ExecCmd)
+	re.RemHost = RenderEnvString(ctx, re.RemHost)
+	re.OnlyIf = RenderEnvString(ctx, re.OnlyIf)
+	re.NotIf = RenderEnvString(ctx, re.NotIf)
 }
 
-func (re *RemoteExec) Execute() error {
-	re.Setup()
+func (re *RemoteExec) Execute(ctx context.Context) (*Result, error) {
+	re = runCopy(re)
+	re.Setup(ctx)
 	usr, err := user.Current()
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get current user")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to get current user")
+		return nil, err
+	}
+	if DryRun() {
+		planned(ctx, "run on %s: %s", re.RemHost, re.ExecCmd)
+		return Changed("would run on %s: %s", re.RemHost, re.ExecCmd), nil
 	}
 	uname := usr.Username
 	hostname := re.RemHost
@@ -37,39 +47,40 @@ func (re *RemoteExec) Execute() error {
 		uname = strings.Split(re.RemHost, "@")[0]
 		hostname = strings.Split(re.RemHost, "@")[1]
 	}
-	rs, err := rssh.NewRSSH(hostname, uname, re.PrivKey)
+	rs, err := rssh.NewRSSHContext(ctx, hostname, uname, re.PrivKey)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to create RSSH")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to create RSSH")
+		return nil, err
 	}
 	defer rs.Close()
 	if len(re.OnlyIf) > 0 {
-		oif, err := rs.ExecCommand(re.OnlyIf)
+		oif, err := rs.ExecCommandContext(ctx, re.OnlyIf)
 		if err != nil || len(oif) == 0 {
-			log.Info().Msgf("remoteCmd skipping on (onlyIf): %s", re.ExecCmd)
-			return nil
+			log.Ctx(ctx).Info().Msgf("remoteCmd skipping on (onlyIf): %s", re.ExecCmd)
+			return Skipped("onlyIf: %s", re.OnlyIf), nil
 		}
 	}
 	// if notIf is set, check if it's return value is empty / false
 	if len(re.NotIf) > 0 {
-		nif, err := rs.ExecCommand(re.NotIf)
+		nif, err := rs.ExecCommandContext(ctx, re.NotIf)
 		if err == nil || len(nif) > 0 {
-			log.Info().Msgf("remoteCmd skipping on (notIf): %s", re.ExecCmd)
-			return nil
+			log.Ctx(ctx).Info().Msgf("remoteCmd skipping on (notIf): %s", re.ExecCmd)
+			return Skipped("notIf: %s", re.NotIf), nil
 		}
 	}
-	log.Info().Msgf("remoteCmd: %s", re.ExecCmd)
-	output, err := rs.ExecCommand(re.ExecCmd)
+	log.Ctx(ctx).Info().Msgf("remoteCmd: %s", re.ExecCmd)
+	output, err := rs.ExecCommandContext(ctx, re.ExecCmd)
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed to execute %s", re.ExecCmd)
-		return err
-	} else {
-		log.Debug().Str("cmd", re.ExecCmd).Msgf("completed executing on [%s]", re.RemHost)
-		log.Debug().Msgf("Output: %s", output)
-		if len(re.SetEnv) > 0 {
-			log.Debug().Str("remoteCmd", re.SetEnv).Msgf("setting env var: %s=%s", re.SetEnv, re.ExecCmd)
-			log.Error().Err(os.Setenv(re.SetEnv, re.ExecCmd))
-		}
+		log.Ctx(ctx).Error().Err(err).Msgf("Failed to execute %s", re.ExecCmd)
+		return &Result{Status: StatusFailed, Stdout: output}, err
+	}
+	log.Ctx(ctx).Debug().Str("cmd", re.ExecCmd).Msgf("completed executing on [%s]", re.RemHost)
+	log.Ctx(ctx).Debug().Msgf("Output: %s", output)
+	if len(re.SetEnv) > 0 {
+		log.Ctx(ctx).Debug().Str("remoteCmd", re.ExecCmd).Msgf("setting env var: %s=%s", re.SetEnv, output)
+		vars.FromContext(ctx).Set(re.SetEnv, output)
 	}
-	return nil
+	res := Changed("ran on %s: %s", re.RemHost, re.ExecCmd)
+	res.Stdout = output
+	return res, nil
 }
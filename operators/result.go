@@ -0,0 +1,99 @@
+package operators
+
+import (
+	"bruce/changes"
+	"bruce/exe"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Status describes the outcome of an operator execution.
+type Status string
+
+const (
+	StatusOk      Status = "ok"
+	StatusChanged Status = "changed"
+	StatusSkipped Status = "skipped"
+	StatusFailed  Status = "failed"
+)
+
+// Result is returned by every operator execution, the engine fills in the duration and marks failures.
+type Result struct {
+	Status    Status        `json:"status"`
+	Reason    string        `json:"reason,omitempty"`
+	Stdout    string        `json:"stdout,omitempty"`
+	Stderr    string        `json:"stderr,omitempty"`
+	Files     []string      `json:"files,omitempty"`
+	Resources []string      `json:"resources,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Ok returns a result for an execution that found nothing to change.
+func Ok(format string, args ...interface{}) *Result {
+	return &Result{Status: StatusOk, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Changed returns a result for an execution that altered the system (or would have during a dry run).
+func Changed(format string, args ...interface{}) *Result {
+	return &Result{Status: StatusChanged, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Skipped returns a result for an execution that did not run due to os limits or conditions.
+func Skipped(format string, args ...interface{}) *Result {
+	return &Result{Status: StatusSkipped, Reason: fmt.Sprintf(format, args...)}
+}
+
+// WithOutput attaches the output of a command execution to the result.
+func (r *Result) WithOutput(pc *exe.Execution) *Result {
+	r.Stdout = pc.Stdout()
+	r.Stderr = pc.Stderr()
+	return r
+}
+
+// WithFiles records the files altered by the execution.
+func (r *Result) WithFiles(files ...string) *Result {
+	r.Files = append(r.Files, files...)
+	return r
+}
+
+// WithResources records the resources of a kind altered by the execution, eg: the installed packages.
+func (r *Result) WithResources(kind string, names ...string) *Result {
+	for _, n := range names {
+		r.Resources = append(r.Resources, changes.Resource(kind, n))
+	}
+	return r
+}
+
+// Changes returns the files and resources altered by the execution.
+func (r *Result) Changes() []string {
+	return append(append([]string(nil), r.Files...), r.Resources...)
+}
+
+// IsChanged returns true if the result altered the system.
+func (r *Result) IsChanged() bool {
+	return r != nil && r.Status == StatusChanged
+}
+
+// checkConditions evaluates onlyIf / notIf and returns a skipped result if the operator should not run.
+func checkConditions(ctx context.Context, onlyIf, notIf string) *Result {
+	// if onlyIf is set, check if it's return value is not empty / true
+	if len(onlyIf) > 0 {
+		pc := exe.RunContext(ctx, onlyIf, "")
+		if pc.Failed() || len(pc.Get()) == 0 {
+			log.Ctx(ctx).Info().Msgf("skipping on (onlyIf): %s", onlyIf)
+			return Skipped("onlyIf: %s", onlyIf)
+		}
+	}
+	// if notIf is set, check if it's return value is empty / false
+	if len(notIf) > 0 {
+		pc := exe.RunContext(ctx, notIf, "")
+		if !pc.Failed() || len(pc.Get()) > 0 {
+			log.Ctx(ctx).Info().Msgf("skipping on (notIf): %s", notIf)
+			return Skipped("notIf: %s", notIf)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package operators
+
+import (
+	"bruce/changes"
+	"bruce/system"
+	"context"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+func init() {
+	MustRegister("service", func() Operator { return &Services{} })
+}
+
+// Services manages systemd services, a service is only restarted (or reloaded) when restartAlways is set or one
+// of the restartTrigger files was modified during this run. When healthCheck is set the command must succeed after
+// the service was started, restarted or reloaded, within the step timeout (or 30 seconds), otherwise the step fails.
+type Services struct {
+	Service        string   `yaml:"service"`
+	SetEnabled     *bool    `yaml:"setEnabled"`
+	Mask           *bool    `yaml:"mask"`
+	State          string   `yaml:"state"`
+	RestartTrigger []string `yaml:"restartTrigger"`
+	RestartAlways  bool     `yaml:"restartAlways"`
+	Reload         bool     `yaml:"reload"`
+	HealthCheck    string   `yaml:"healthCheck"`
+	OsLimits       string   `yaml:"osLimits"`
+	OnlyIf         string   `yaml:"onlyIf"`
+	NotIf          string   `yaml:"notIf"`
+	changes        []string
+}
+
+func (s *Services) Setup(ctx context.Context) {
+	s.Service = RenderEnvString(ctx, s.Service)
+	s.HealthCheck = RenderEnvString(ctx, s.HealthCheck)
+	s.State = strings.ToLower(s.State)
+	triggers := make([]string, len(s.RestartTrigger))
+	for i, t := range s.RestartTrigger {
+		triggers[i] = RenderEnvString(ctx, t)
+	}
+	s.RestartTrigger = triggers
+	s.changes = nil
+}
+
+// Execute brings the service to the requested state.
+func (s *Services) Execute(ctx context.Context) (*Result, error) {
+	s = runCopy(s)
+	s.Setup(ctx)
+	if !system.Get().CanExecOnOs(s.OsLimits) {
+		log.Ctx(ctx).Info().Str("service", s.Service).Msgf("skipped due to os limit: %s", s.OsLimits)
+		return Skipped("os limit: %s", s.OsLimits), nil
+	}
+	if r := checkConditions(ctx, s.OnlyIf, s.NotIf); r != nil {
+		return r, nil
+	}
+	if len(s.Service) == 0 {
+		return nil, fmt.Errorf("no service name provided")
+	}
+	if system.Get().ServiceController != "systemctl" {
+		return nil, fmt.Errorf("unsupported service controller: %q", system.Get().ServiceController)
+	}
+	// unit files written during this run must be reloaded before systemd will act on them
+	if s.unitFilesModified(ctx) {
+		if err := s.systemctl(ctx, "daemon-reload"); err != nil {
+			return nil, err
+		}
+	}
+
+	enabledState := s.query(ctx, "is-enabled")
+	if s.Mask != nil {
+		if *s.Mask && enabledState != "masked" {
+			if err := s.systemctl(ctx, "mask", s.Service); err != nil {
+				return nil, err
+			}
+			s.changes = append(s.changes, "masked")
+		}
+		if !*s.Mask && enabledState == "masked" {
+			if err := s.systemctl(ctx, "unmask", s.Service); err != nil {
+				return nil, err
+			}
+			s.changes = append(s.changes, "unmasked")
+		}
+		enabledState = s.query(ctx, "is-enabled")
+	}
+	if s.SetEnabled != nil {
+		if *s.SetEnabled && enabledState != "enabled" {
+			if err := s.systemctl(ctx, "enable", s.Service); err != nil {
+				return nil, err
+			}
+			s.changes = append(s.changes, "enabled")
+		}
+		if !*s.SetEnabled && enabledState == "enabled" {
+			if err := s.systemctl(ctx, "disable", s.Service); err != nil {
+				return nil, err
+			}
+			s.changes = append(s.changes, "disabled")
+		}
+	}
+
+	active := s.query(ctx, "is-active") == "active"
+	switch s.State {
+	case "started", "running":
+		if !active {
+			if err := s.systemctl(ctx, "start", s.Service); err != nil {
+				return nil, err
+			}
+			s.changes = append(s.changes, "started")
+		} else if s.triggered(ctx) {
+			if err := s.restart(ctx); err != nil {
+				return nil, err
+			}
+		}
+	case "stopped":
+		if active {
+			if err := s.systemctl(ctx, "stop", s.Service); err != nil {
+				return nil, err
+			}
+			s.changes = append(s.changes, "stopped")
+		}
+	case "restarted":
+		if err := s.systemctl(ctx, "restart", s.Service); err != nil {
+			return nil, err
+		}
+		s.changes = append(s.changes, "restarted")
+	case "reloaded":
+		if err := s.systemctl(ctx, "reload", s.Service); err != nil {
+			return nil, err
+		}
+		s.changes = append(s.changes, "reloaded")
+	case "":
+		if active && s.triggered(ctx) {
+			if err := s.restart(ctx); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown service state: %s", s.State)
+	}
+
+	if len(s.changes) > 0 {
+		if err := s.checkHealth(ctx); err != nil {
+			return Changed("service %s: %s", s.Service, strings.Join(s.changes, ", ")).WithResources("service", s.Service), err
+		}
+		log.Ctx(ctx).Info().Msgf("service %s: %s", s.Service, strings.Join(s.changes, ", "))
+		return Changed("service %s: %s", s.Service, strings.Join(s.changes, ", ")).WithResources("service", s.Service), nil
+	}
+	log.Ctx(ctx).Info().Msgf("service %s: no changes", s.Service)
+	return Ok("service %s: no changes", s.Service), nil
+}
+
+// triggered returns true if the service should be restarted due to restartAlways or a trigger changed during the run.
+func (s *Services) triggered(ctx context.Context) bool {
+	if s.RestartAlways {
+		return true
+	}
+	reg := changes.FromContext(ctx)
+	for _, t := range s.RestartTrigger {
+		if reg.Changed(t) {
+			log.Ctx(ctx).Debug().Msgf("service %s triggered by: %s", s.Service, t)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Services) restart(ctx context.Context) error {
+	action := "restart"
+	if s.Reload {
+		action = "reload"
+	}
+	if err := s.systemctl(ctx, action, s.Service); err != nil {
+		return err
+	}
+	s.changes = append(s.changes, action+"ed")
+	return nil
+}
+
+// checkHealth runs the health check until it succeeds when the service was started, restarted or reloaded.
+func (s *Services) checkHealth(ctx context.Context) error {
+	if len(s.HealthCheck) == 0 || s.State == "stopped" {
+		return nil
+	}
+	if DryRun() {
+		planned(ctx, "health check: %s", s.HealthCheck)
+		return nil
+	}
+	log.Ctx(ctx).Info().Msgf("service %s health check: %s", s.Service, s.HealthCheck)
+	var out string
+	var last error
+	err := waitFor(ctx, fmt.Sprintf("service %s health check", s.Service), func() bool {
+		out, last = runCommand(ctx, s.HealthCheck)
+		return last == nil
+	})
+	if err != nil {
+		if last != nil {
+			log.Ctx(ctx).Error().Err(last).Msg(out)
+		}
+		return err
+	}
+	return nil
+}
+
+// unitFilesModified returns true if a systemd unit file changed during the run so the daemon must be reloaded.
+func (s *Services) unitFilesModified(ctx context.Context) bool {
+	return changes.FromContext(ctx).Under("/etc/systemd/", "/usr/lib/systemd/", "/lib/systemd/")
+}
+
+// query returns the trimmed output of a systemctl status command such as is-active / is-enabled.
+func (s *Services) query(ctx context.Context, action string) string {
+	out, _ := runCommand(ctx, fmt.Sprintf("%s %s %s", system.Get().ServiceControllerPath, action, s.Service))
+	return strings.TrimSpace(out)
+}
+
+func (s *Services) systemctl(ctx context.Context, args ...string) error {
+	c := fmt.Sprintf("%s %s", system.Get().ServiceControllerPath, strings.Join(args, " "))
+	if DryRun() {
+		planned(ctx, "run: %s", c)
+		return nil
+	}
+	log.Ctx(ctx).Debug().Msgf("service cmd: %s", c)
+	out, err := runCommand(ctx, c)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg(out)
+		return fmt.Errorf("%s: %w", c, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package operators
+
+import (
+	"bruce/changes"
+	"bruce/system"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServicesExecute(t *testing.T) {
+	enabled, disabled := true, false
+	tests := []struct {
+		name       string
+		op         *Services
+		outputs    map[string]string
+		changed    []string
+		fail       map[string]bool
+		wantStatus Status
+		wantRan    []string
+		wantErr    bool
+	}{
+		{
+			name:       "enable and start",
+			op:         &Services{Service: "nginx", SetEnabled: &enabled, State: "started"},
+			outputs:    map[string]string{"systemctl is-enabled nginx": "disabled", "systemctl is-active nginx": "inactive"},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"systemctl enable nginx", "systemctl start nginx"},
+		},
+		{
+			name:       "already enabled and running",
+			op:         &Services{Service: "nginx", SetEnabled: &enabled, State: "Running"},
+			outputs:    map[string]string{"systemctl is-enabled nginx": "enabled", "systemctl is-active nginx": "active"},
+			wantStatus: StatusOk,
+		},
+		{
+			name:       "disable and stop",
+			op:         &Services{Service: "nginx", SetEnabled: &disabled, State: "stopped"},
+			outputs:    map[string]string{"systemctl is-enabled nginx": "enabled", "systemctl is-active nginx": "active"},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"systemctl disable nginx", "systemctl stop nginx"},
+		},
+		{
+			name:       "unmask",
+			op:         &Services{Service: "nginx", Mask: &disabled},
+			outputs:    map[string]string{"systemctl is-enabled nginx": "masked"},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"systemctl unmask nginx"},
+		},
+		{
+			name:       "restart when a trigger changed",
+			op:         &Services{Service: "nginx", State: "started", RestartTrigger: []string{"/etc/nginx/"}},
+			outputs:    map[string]string{"systemctl is-active nginx": "active"},
+			changed:    []string{"/etc/nginx/nginx.conf"},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"systemctl restart nginx"},
+		},
+		{
+			name:       "reload when a trigger changed",
+			op:         &Services{Service: "nginx", Reload: true, RestartTrigger: []string{"package:nginx"}},
+			outputs:    map[string]string{"systemctl is-active nginx": "active"},
+			changed:    []string{"package:nginx"},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"systemctl reload nginx"},
+		},
+		{
+			name:       "no restart without a changed trigger",
+			op:         &Services{Service: "nginx", State: "started", RestartTrigger: []string{"/etc/nginx/"}},
+			outputs:    map[string]string{"systemctl is-active nginx": "active"},
+			changed:    []string{"/etc/app.conf"},
+			wantStatus: StatusOk,
+		},
+		{
+			name:       "restart always",
+			op:         &Services{Service: "nginx", RestartAlways: true},
+			outputs:    map[string]string{"systemctl is-active nginx": "active"},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"systemctl restart nginx"},
+		},
+		{
+			name:       "daemon reload for changed unit files",
+			op:         &Services{Service: "app", State: "restarted"},
+			changed:    []string{"/etc/systemd/system/app.service"},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"systemctl daemon-reload", "systemctl restart app"},
+		},
+		{
+			name:       "health check",
+			op:         &Services{Service: "app", State: "restarted", HealthCheck: "curl -sf http://localhost"},
+			wantStatus: StatusChanged,
+			wantRan:    []string{"systemctl restart app", "curl -sf http://localhost"},
+		},
+		{
+			name:    "failed health check",
+			op:      &Services{Service: "app", State: "restarted", HealthCheck: "curl -sf http://localhost"},
+			fail:    map[string]bool{"curl -sf http://localhost": true},
+			wantRan: []string{"systemctl restart app"},
+			wantErr: true,
+		},
+		{
+			name:    "failed start",
+			op:      &Services{Service: "app", State: "started"},
+			fail:    map[string]bool{"systemctl start app": true},
+			wantErr: true,
+		},
+		{
+			name:    "unknown state",
+			op:      &Services{Service: "app", State: "paused"},
+			wantErr: true,
+		},
+		{
+			name:    "no service",
+			op:      &Services{State: "started"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeRunner{outputs: tt.outputs, fail: tt.fail}
+			useRunner(t, f, func(s *system.SystemInfo) {
+				s.ServiceController, s.ServiceControllerPath = "systemctl", "systemctl"
+			})
+			reg := changes.New()
+			reg.Record(1, tt.changed...)
+			ctx, cancel := context.WithTimeout(changes.WithRegistry(context.Background(), reg), time.Second)
+			defer cancel()
+			res, err := tt.op.Execute(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && res.Status != tt.wantStatus {
+				t.Errorf("Execute() status = %s, want %s (%s)", res.Status, tt.wantStatus, res.Reason)
+			}
+			if !tt.wantErr && tt.wantStatus == StatusChanged && !reflect.DeepEqual(res.Resources, []string{"service:" + tt.op.Service}) {
+				t.Errorf("Execute() resources = %v, want the service", res.Resources)
+			}
+			if !reflect.DeepEqual(f.ran, tt.wantRan) {
+				t.Errorf("Execute() ran %q, want %q", f.ran, tt.wantRan)
+			}
+		})
+	}
+}
+
+func TestServicesUnsupportedController(t *testing.T) {
+	useRunner(t, &fakeRunner{}, func(s *system.SystemInfo) { s.ServiceController = "" })
+	if _, err := (&Services{Service: "nginx", State: "started"}).Execute(context.Background()); err == nil {
+		t.Errorf("Execute() expected an error without systemctl")
+	}
+}
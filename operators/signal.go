@@ -1,69 +1,281 @@
 package operators
 
 import (
+	"bruce/changes"
 	"bruce/exe"
+	"bruce/system"
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
-	"syscall"
+	"time"
 )
 
+func init() {
+	MustRegister("signal", func() Operator { return &Signals{} })
+	MustRegister("pidFile", func() Operator { return &Signals{} })
+}
+
+// Signals sends a signal to processes found by pid file, process name or systemd unit.
+// When restartTrigger is set the signal is only sent if one of the trigger files was modified during this run.
+// Waiting is bounded by the step timeout, or 30 seconds when the step has none.
 type Signals struct {
-	PidFile string `yaml:"pidFile"`
-	Signal  string `yaml:"signal"`
-	OnlyIf  string `yaml:"onlyIf"`
-	NotIf   string `yaml:"notIf"`
+	Signal         string   `yaml:"signal"`
+	PidFile        string   `yaml:"pidFile"`
+	Process        string   `yaml:"process"`
+	Unit           string   `yaml:"unit"`
+	Wait           string   `yaml:"wait"`
+	RestartTrigger []string `yaml:"restartTrigger"`
+	OsLimits       string   `yaml:"osLimits"`
+	OnlyIf         string   `yaml:"onlyIf"`
+	NotIf          string   `yaml:"notIf"`
 }
 
-func (s *Signals) Execute() error {
-	if len(s.OnlyIf) > 0 {
-		pc := exe.Run(s.OnlyIf, "")
-		if pc.Failed() || len(pc.Get()) == 0 {
-			log.Info().Msgf("skipping on (onlyIf): %s", s.OnlyIf)
-			return nil
-		}
+func (s *Signals) Setup(ctx context.Context) {
+	s.PidFile = RenderEnvString(ctx, s.PidFile)
+	s.Process = RenderEnvString(ctx, s.Process)
+	s.Unit = RenderEnvString(ctx, s.Unit)
+	s.Wait = strings.ToLower(s.Wait)
+	triggers := make([]string, len(s.RestartTrigger))
+	for i, t := range s.RestartTrigger {
+		triggers[i] = RenderEnvString(ctx, t)
 	}
-	// if notIf is set, check if it's return value is empty / false
-	if len(s.NotIf) > 0 {
-		pc := exe.Run(s.NotIf, "")
-		if !pc.Failed() || len(pc.Get()) > 0 {
-			log.Info().Msgf("skipping on (notIf): %s", s.NotIf)
-			return nil
-		}
+	s.RestartTrigger = triggers
+}
+
+func (s *Signals) Execute(ctx context.Context) (*Result, error) {
+	s = runCopy(s)
+	s.Setup(ctx)
+	if !system.Get().CanExecOnOs(s.OsLimits) {
+		log.Ctx(ctx).Info().Str("signal", s.Signal).Msgf("skipped due to os limit: %s", s.OsLimits)
+		return Skipped("os limit: %s", s.OsLimits), nil
 	}
-	if _, err := os.Stat(s.PidFile); os.IsNotExist(err) {
-		err = fmt.Errorf("pidfile does not exist at: %s", s.PidFile)
-		return err
+	if r := checkConditions(ctx, s.OnlyIf, s.NotIf); r != nil {
+		return r, nil
 	}
-	d, err := os.ReadFile(s.PidFile)
-	if err != nil {
-		log.Error().Err(err).Msg("pid file read error")
-		return err
+	if len(s.RestartTrigger) > 0 && !s.triggered(ctx) {
+		log.Ctx(ctx).Info().Msgf("signal %s skipped, no restart triggers were modified", s.Signal)
+		return Ok("no restart triggers were modified"), nil
 	}
-
-	pid, err := strconv.Atoi(string(bytes.TrimSpace(d)))
+	sig, err := lookupSignal(s.Signal)
 	if err != nil {
-		log.Error().Err(err).Msgf("could not reading pid file: %s", s.PidFile)
-		return err
+		return nil, err
+	}
+	if err := s.validateWait(); err != nil {
+		return nil, err
 	}
-	p, err := os.FindProcess(pid)
+	pids, err := s.pids(ctx)
 	if err != nil {
-		log.Error().Err(err).Msgf("could not find process for pid: %d", pid)
-		return err
+		return nil, err
 	}
-	switch strings.ToUpper(s.Signal) {
-	case "SIGINT":
-		p.Signal(syscall.SIGINT)
-		return nil
-	case "SIGHUP":
-		p.Signal(syscall.SIGHUP)
+	pidFileStat := s.pidFileState()
+	children := make(map[int]string)
+	for _, pid := range pids {
+		children[pid] = childPids(pid)
+	}
+	for _, pid := range pids {
+		p, err := os.FindProcess(pid)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("could not find process for pid: %d", pid)
+			return nil, err
+		}
+		if DryRun() {
+			planned(ctx, "signal: %s => %d", s.Signal, pid)
+			continue
+		}
+		log.Ctx(ctx).Info().Msgf("signal: %s => %d", s.Signal, pid)
+		if err := p.Signal(sig); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("could not signal pid: %d", pid)
+			return nil, err
+		}
+	}
+	res := Changed("sent %s to %d process(es)", s.Signal, len(pids))
+	if DryRun() {
+		return Changed("would send %s to %d process(es)", s.Signal, len(pids)), nil
+	}
+	switch s.Wait {
+	case "exit":
+		return res, waitFor(ctx, "process exit", func() bool {
+			for _, pid := range pids {
+				if processAlive(pid) {
+					return false
+				}
+			}
+			return true
+		})
+	case "reload":
+		// a reload is considered complete once the pid file was rewritten or the child processes were replaced.
+		return res, waitFor(ctx, "process reload", func() bool {
+			if s.pidFileState() != pidFileStat {
+				return true
+			}
+			for _, pid := range pids {
+				if childPids(pid) == children[pid] {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return res, nil
+}
+
+// validateWait checks the wait mode before any signal is sent. A reload is detected by the pid file being rewritten,
+// without a pid file only replaced child processes are noticed which a process reloading in place never shows.
+func (s *Signals) validateWait() error {
+	switch s.Wait {
+	case "", "exit":
 		return nil
-	default:
-		p.Signal(syscall.SIGHUP)
+	case "reload":
+		if len(s.PidFile) == 0 {
+			return fmt.Errorf("signal wait: reload requires a pidFile, use wait: exit or no wait for process / unit")
+		}
 		return nil
 	}
+	return fmt.Errorf("unknown signal wait: %s (must be exit or reload)", s.Wait)
+}
+
+// triggered returns true if any of the restart triggers were changed during this run.
+func (s *Signals) triggered(ctx context.Context) bool {
+	reg := changes.FromContext(ctx)
+	for _, t := range s.RestartTrigger {
+		if reg.Changed(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// pids resolves the process ids to signal from the pid file, process name or systemd unit.
+func (s *Signals) pids(ctx context.Context) ([]int, error) {
+	switch {
+	case len(s.PidFile) > 0:
+		d, err := os.ReadFile(s.PidFile)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("pid file read error")
+			return nil, err
+		}
+		pid, err := strconv.Atoi(string(bytes.TrimSpace(d)))
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("could not reading pid file: %s", s.PidFile)
+			return nil, err
+		}
+		return []int{pid}, nil
+	case len(s.Process) > 0:
+		pids := findProcessByName(s.Process)
+		if len(pids) == 0 {
+			return nil, fmt.Errorf("no running process found named: %s", s.Process)
+		}
+		return pids, nil
+	case len(s.Unit) > 0:
+		pc := exe.Run(fmt.Sprintf("systemctl show -p MainPID --value %s", s.Unit), "")
+		if pc.Failed() {
+			return nil, fmt.Errorf("could not read main pid for unit %s: %w", s.Unit, pc.GetErr())
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(pc.Get()))
+		if err != nil || pid == 0 {
+			return nil, fmt.Errorf("unit %s is not running", s.Unit)
+		}
+		return []int{pid}, nil
+	}
+	return nil, fmt.Errorf("signal requires one of pidFile, process or unit")
+}
+
+func (s *Signals) pidFileState() string {
+	if len(s.PidFile) == 0 {
+		return ""
+	}
+	fi, err := os.Stat(s.PidFile)
+	if err != nil {
+		return "missing"
+	}
+	d, _ := os.ReadFile(s.PidFile)
+	return fmt.Sprintf("%d:%s", fi.ModTime().UnixNano(), bytes.TrimSpace(d))
+}
+
+// findProcessByName returns the pids with a matching command name, using /proc where available or pgrep otherwise.
+func findProcessByName(name string) []int {
+	var pids []int
+	if exe.FileExists("/proc/self/comm") {
+		matches, _ := filepath.Glob("/proc/[0-9]*/comm")
+		for _, m := range matches {
+			d, err := os.ReadFile(m)
+			if err != nil || strings.TrimSpace(string(d)) != name {
+				continue
+			}
+			if pid, err := strconv.Atoi(filepath.Base(filepath.Dir(m))); err == nil {
+				pids = append(pids, pid)
+			}
+		}
+		return pids
+	}
+	pc := exe.Run(fmt.Sprintf("pgrep -x %s", name), "")
+	for _, f := range strings.Fields(pc.Get()) {
+		if pid, err := strconv.Atoi(f); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// childPids returns a sorted, comma separated list of the child processes for pid.
+func childPids(pid int) string {
+	var children []int
+	if exe.FileExists("/proc/self/stat") {
+		matches, _ := filepath.Glob("/proc/[0-9]*/stat")
+		for _, m := range matches {
+			d, err := os.ReadFile(m)
+			if err != nil {
+				continue
+			}
+			// the command name may contain spaces so fields are read after the closing bracket
+			i := bytes.LastIndexByte(d, ')')
+			if i < 0 {
+				continue
+			}
+			fields := strings.Fields(string(d[i+1:]))
+			if len(fields) > 1 && fields[1] == strconv.Itoa(pid) {
+				if c, err := strconv.Atoi(filepath.Base(filepath.Dir(m))); err == nil {
+					children = append(children, c)
+				}
+			}
+		}
+	} else {
+		pc := exe.Run(fmt.Sprintf("pgrep -P %d", pid), "")
+		for _, f := range strings.Fields(pc.Get()) {
+			if c, err := strconv.Atoi(f); err == nil {
+				children = append(children, c)
+			}
+		}
+	}
+	sort.Ints(children)
+	var out []string
+	for _, c := range children {
+		out = append(out, strconv.Itoa(c))
+	}
+	return strings.Join(out, ",")
+}
+
+// waitFor polls done until it returns true or ctx is done, a 30 second limit applies when ctx has no deadline.
+func waitFor(ctx context.Context, what string, done func() bool) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+	}
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s: %w", what, ctx.Err())
+		case <-ticker.C:
+		}
+	}
 	return nil
 }
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package operators
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// lookupSignal accepts signal names with or without the SIG prefix (HUP / SIGHUP) or a signal number.
+func lookupSignal(name string) (os.Signal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name == "" {
+		return syscall.SIGHUP, nil
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		return syscall.Signal(n), nil
+	}
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig := unix.SignalNum(name); sig != 0 {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unknown signal: %s", name)
+}
+
+func processAlive(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
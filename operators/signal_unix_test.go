@@ -0,0 +1,55 @@
+//go:build !windows
+
+package operators
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestLookupSignal(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    syscall.Signal
+		wantErr bool
+	}{
+		{name: "full name", input: "SIGHUP", want: syscall.SIGHUP},
+		{name: "short name", input: "usr1", want: syscall.SIGUSR1},
+		{name: "number", input: "15", want: syscall.SIGTERM},
+		{name: "default", input: "", want: syscall.SIGHUP},
+		{name: "unknown", input: "SIGFOO", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupSignal(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookupSignal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("lookupSignal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignalsValidateWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      *Signals
+		wantErr bool
+	}{
+		{name: "no wait", op: &Signals{Process: "nginx"}},
+		{name: "exit", op: &Signals{Process: "nginx", Wait: "exit"}},
+		{name: "reload with pid file", op: &Signals{PidFile: "/var/run/nginx.pid", Wait: "reload"}},
+		{name: "reload without pid file", op: &Signals{Unit: "nginx.service", Wait: "reload"}, wantErr: true},
+		{name: "unknown", op: &Signals{PidFile: "/var/run/nginx.pid", Wait: "restart"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op.validateWait(); (err != nil) != tt.wantErr {
+				t.Errorf("validateWait() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package operators
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// lookupSignal only supports interrupt and kill on windows.
+func lookupSignal(name string) (os.Signal, error) {
+	switch strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG") {
+	case "INT":
+		return os.Interrupt, nil
+	case "KILL":
+		return os.Kill, nil
+	}
+	return nil, fmt.Errorf("unsupported signal on windows: %s", name)
+}
+
+func processAlive(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
@@ -3,10 +3,15 @@ package operators
 import (
 	"bruce/exe"
 	"bruce/mutation"
+	"context"
 	"fmt"
 	"github.com/rs/zerolog/log"
 )
 
+func init() {
+	MustRegister("tarball", func() Operator { return &Tarball{} })
+}
+
 type Tarball struct {
 	Name   string `yaml:"name"`
 	Src    string `yaml:"tarball"`
@@ -17,31 +22,32 @@ type Tarball struct {
 	NotIf  string `yaml:"notIf"`
 }
 
-func (t *Tarball) Setup() {
-	t.Src = RenderEnvString(t.Src)
-	t.Dest = RenderEnvString(t.Dest)
+func (t *Tarball) Setup(ctx context.Context) {
+	t.Src = RenderEnvString(ctx, t.Src)
+	t.Dest = RenderEnvString(ctx, t.Dest)
 }
 
-func (t *Tarball) Execute() error {
-	t.Setup()
-	if len(t.OnlyIf) > 0 {
-		pc := exe.Run(t.OnlyIf, "")
-		if pc.Failed() || len(pc.Get()) == 0 {
-			log.Info().Msgf("skipping on (onlyIf): %s", t.OnlyIf)
-			return nil
-		}
-	}
-	// if notIf is set, check if it's return value is empty / false
-	if len(t.NotIf) > 0 {
-		pc := exe.Run(t.NotIf, "")
-		if !pc.Failed() || len(pc.Get()) > 0 {
-			log.Info().Msgf("skipping on (notIf): %s", t.NotIf)
-			return nil
-		}
+func (t *Tarball) Execute(ctx context.Context) (*Result, error) {
+	t = runCopy(t)
+	t.Setup(ctx)
+	if r := checkConditions(ctx, t.OnlyIf, t.NotIf); r != nil {
+		return r, nil
 	}
 	if len(t.Src) < 1 {
-		return fmt.Errorf("source is too short")
+		return nil, fmt.Errorf("source is too short")
+	}
+	// extraction is skipped when the destination exists unless forced
+	if exe.FileExists(t.Dest) && !t.Force {
+		log.Ctx(ctx).Info().Msgf("%s already exists cannot extract tarball to location", t.Dest)
+		return Ok("destination exists: %s", t.Dest), nil
+	}
+	if DryRun() {
+		planned(ctx, "extract tarball: %s => %s", t.Src, t.Dest)
+		return Changed("would extract: %s => %s", t.Src, t.Dest).WithFiles(t.Dest), nil
+	}
+	log.Ctx(ctx).Info().Msgf("tarball: %s => %s", t.Src, t.Dest)
+	if err := mutation.ExtractTarball(ctx, t.Src, t.Dest, t.Force, t.Strip); err != nil {
+		return nil, err
 	}
-	log.Info().Msgf("tarball: %s => %s", t.Src, t.Dest)
-	return mutation.ExtractTarball(t.Src, t.Dest, t.Force, t.Strip)
+	return Changed("extracted: %s => %s", t.Src, t.Dest).WithFiles(t.Dest), nil
 }
@@ -1,12 +1,12 @@
 package operators
 
 import (
+	"bruce/backup"
 	"bruce/exe"
 	"bruce/loader"
-	"bruce/random"
-	"bruce/system"
+	"bruce/vars"
 	"bytes"
-	"fmt"
+	"context"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/rs/zerolog/log"
 	"io/fs"
@@ -23,12 +23,10 @@ var (
 		"contains": strings.Contains,
 		"dump":     func(field interface{}) string { return dump(field) },
 	}
-	backupDir string
 )
 
 func init() {
-	backupDir = fmt.Sprintf("%s%c%s", os.TempDir(), os.PathSeparator, random.String(12))
-	os.MkdirAll(backupDir, 0775)
+	MustRegister("template", func() Operator { return &Template{} })
 }
 
 type Template struct {
@@ -42,9 +40,16 @@ type Template struct {
 	NotIf     string      `yaml:"notIf"`
 }
 
-func (t *Template) Setup() {
-	t.Template = RenderEnvString(t.Template)
-	t.RemoteLoc = RenderEnvString(t.RemoteLoc)
+func (t *Template) Setup(ctx context.Context) {
+	t.Template = RenderEnvString(ctx, t.Template)
+	t.RemoteLoc = RenderEnvString(ctx, t.RemoteLoc)
+	t.Owner = RenderEnvString(ctx, t.Owner)
+	t.Group = RenderEnvString(ctx, t.Group)
+}
+
+// Relocate resolves a relative template source against the role defining the step.
+func (t *Template) Relocate(resolve func(string) string) {
+	t.RemoteLoc = resolve(t.RemoteLoc)
 }
 
 type TVars struct {
@@ -59,103 +64,99 @@ func dump(field interface{}) string {
 	return buf.String()
 }
 
-func (t *Template) Execute() error {
-	t.Setup()
-	if len(t.OnlyIf) > 0 {
-		pc := exe.Run(t.OnlyIf, "")
-		if pc.Failed() || len(pc.Get()) == 0 {
-			log.Info().Msgf("skipping on (onlyIf): %s", t.OnlyIf)
-			return nil
-		}
+func (t *Template) Execute(ctx context.Context) (*Result, error) {
+	t = runCopy(t)
+	t.Setup(ctx)
+	if r := checkConditions(ctx, t.OnlyIf, t.NotIf); r != nil {
+		return r, nil
 	}
-	// if notIf is set, check if it's return value is empty / false
-	if len(t.NotIf) > 0 {
-		pc := exe.Run(t.NotIf, "")
-		if !pc.Failed() || len(pc.Get()) > 0 {
-			log.Info().Msgf("skipping on (notIf): %s", t.NotIf)
-			return nil
+	if DryRun() {
+		changed, err := ExecuteTemplate(ctx, t.Template, t.RemoteLoc, t.Variables, t.Perms)
+		if err != nil {
+			return nil, err
 		}
-	}
-	log.Debug().Msgf("using template backup directory as: %s", backupDir)
-	// backup existing template if exists
-	if exe.FileExists(t.Template) {
-		log.Debug().Msgf("backing up existing template: %s", t.Template)
-		err := exe.CopyFile(t.Template, fmt.Sprintf("%s%c%s", backupDir, os.PathSeparator, path.Base(t.Template)), false)
+		owned, err := planOwnership(ctx, t.Template, t.Owner, t.Group, t.Perms)
 		if err != nil {
-			log.Debug().Err(err).Msgf("could not create backup file: %s", err)
-			return err
+			return nil, err
+		}
+		if !changed && !owned {
+			return Ok("template unchanged: %s", t.Template), nil
 		}
-	} else {
-		log.Debug().Str("template", t.Template).Msg("no existing template file exists")
+		return Changed("would write template: %s", t.Template).WithFiles(t.Template), nil
 	}
-	log.Info().Msgf("template: %s => %s", t.RemoteLoc, t.Template)
-	return ExecuteTemplate(t.Template, t.RemoteLoc, t.Variables, t.Perms)
-	// run template exec on file
+	log.Ctx(ctx).Info().Msgf("template: %s => %s", t.RemoteLoc, t.Template)
+	changed, err := ExecuteTemplate(ctx, t.Template, t.RemoteLoc, t.Variables, t.Perms)
+	if err != nil {
+		return nil, err
+	}
+	n, err := setOwnership(ctx, t.Template, t.Owner, t.Group, t.Perms, 0, false)
+	if err != nil {
+		return nil, err
+	}
+	if changed || n > 0 {
+		return Changed("template written: %s", t.Template).WithFiles(t.Template), nil
+	}
+	return Ok("template unchanged: %s", t.Template), nil
 }
 
 func GetBackupFileChecksum(src string) (string, error) {
-	backupFileName := fmt.Sprintf("%s%c%s", backupDir, os.PathSeparator, strings.TrimLeft(src, string(os.PathSeparator)))
-	return exe.GetFileChecksum(backupFileName)
+	return exe.GetFileChecksum(backup.FileName(src))
 }
 
-func ExecuteTemplate(local, remote string, vars []TVars, perms fs.FileMode) error {
-	// we have the backup so now we can delete the file if it exists
-	if exe.FileExists(local) {
-		exe.DeleteFile(local)
-	} else {
-		// check if the directories exist to render the file
-		if !exe.FileExists(path.Dir(local)) {
-			os.MkdirAll(path.Dir(local), 0775)
-		}
-	}
-
-	log.Debug().Msgf("template exec starting on: %s", local)
-	t, err := loadTemplateFromRemote(remote)
+// ExecuteTemplate renders the remote template to the local file, the file is only written when the rendered content
+// differs from the existing file. It returns true if the file changed.
+func ExecuteTemplate(ctx context.Context, local, remote string, tvars []TVars, perms fs.FileMode) (bool, error) {
+	log.Ctx(ctx).Debug().Msgf("template exec starting on: %s", local)
+	d, err := renderTemplate(ctx, remote, tvars)
 	if err != nil {
-		log.Err(err).Msgf("cannot read template source %s", local)
-		return err
+		log.Ctx(ctx).Err(err).Msgf("cannot render template source for %s", local)
+		return false, err
 	}
-
-	var content = make(map[string]string)
-	// foreach environment variable we need to load the value
-	for _, e := range os.Environ() {
-		if i := strings.Index(e, "="); i >= 0 {
-			content[e[:i]] = e[i+1:]
-		}
+	if DryRun() {
+		return planFile(ctx, local, d), nil
 	}
-	// then we override with the associated template variables
-	for _, v := range vars {
-		content[v.Variable] = loadTemplateValue(v)
+	existing, err := os.ReadFile(local)
+	if err == nil && bytes.Equal(existing, d) {
+		log.Ctx(ctx).Info().Msgf("template unchanged: %s", local)
+		return false, nil
 	}
-
-	destination, err := os.OpenFile(local, os.O_RDWR|os.O_CREATE, 0664)
-	if err != nil {
-		log.Error().Err(err).Msgf("could not open file for writing template: %s", local)
-		return err
+	// keep the previous template so the change can be rolled back
+	if err := backup.Save(ctx, local); err != nil {
+		log.Ctx(ctx).Err(err).Msgf("could not back up template: %s", local)
+		return false, err
 	}
-	defer destination.Close()
-	err = t.Execute(destination, content)
-	if err != nil {
-		log.Err(err).Msgf("could not write template: %s", local)
-		return err
+	// check if the directories exist to render the file
+	if !exe.FileExists(path.Dir(local)) {
+		os.MkdirAll(path.Dir(local), 0775)
 	}
-	log.Info().Msgf("template written: %s", local)
-	localHash, err := exe.GetFileChecksum(local)
+	err = os.WriteFile(local, d, 0664)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to get new file checksum")
+		log.Ctx(ctx).Err(err).Msgf("could not write template: %s", local)
+		return false, err
 	}
-	backupHash, err := GetBackupFileChecksum(local)
+	log.Ctx(ctx).Info().Msgf("template written: %s", local)
+	return true, nil
+}
+
+// renderTemplate loads the remote template and executes it with the run variables and template variables.
+func renderTemplate(ctx context.Context, remote string, tvars []TVars) ([]byte, error) {
+	t, err := loadTemplateFromRemote(ctx, remote)
 	if err != nil {
-		// no backup exists so lets add it as a changed template as it should be net new.
-		log.Info().Msgf("no backup file for %s", local)
+		return nil, err
 	}
-	if localHash != backupHash {
-		system.Get().AddModifiedTemplate(local)
+	content := vars.FromContext(ctx).Map()
+	// then we override with the associated template variables
+	for _, v := range tvars {
+		content[v.Variable] = loadTemplateValue(ctx, v)
+	}
+	b := &bytes.Buffer{}
+	if err := t.Execute(b, content); err != nil {
+		return nil, err
 	}
-	return nil
+	return b.Bytes(), nil
 }
 
-func loadTemplateValue(v TVars) string {
+func loadTemplateValue(ctx context.Context, v TVars) string {
 	if v.ObType == "value" {
 		return GetValueForOSHandler(v.Input)
 	}
@@ -164,39 +165,41 @@ func loadTemplateValue(v TVars) string {
 		cText := strings.Fields(v.Input)
 		if len(cText) > 1 {
 			cmd := exec.Command(cText[0], cText[1:]...)
+			cmd.Env = vars.FromContext(ctx).Environ()
 			cmd.Stdout = &outb
 			cmd.Stderr = &errb
 			err := cmd.Run()
 			if err != nil {
-				log.Err(err).Msg("error executing command returning error statement")
+				log.Ctx(ctx).Err(err).Msg("error executing command returning error statement")
 				// we don't want to put crazy errors in our templates anyway...
 				return "ERROR_IN_CMD"
 			}
 		} else {
 			cmd := exec.Command(v.Input)
+			cmd.Env = vars.FromContext(ctx).Environ()
 			cmd.Stdout = &outb
 			cmd.Stderr = &errb
 			err := cmd.Run()
 			if err != nil {
-				log.Err(err).Msg("error executing command returning error statement")
+				log.Ctx(ctx).Err(err).Msg("error executing command returning error statement")
 				// we don't want to put crazy errors in our templates anyway...
 				return "ERROR_IN_CMD"
 			}
 		}
-		fmt.Println(cText[0])
-
+		log.Ctx(ctx).Debug().Msgf("template variable %s set from: %s", v.Variable, cText[0])
 		return outb.String()
 	}
 	// sometimes we will actually want an empty string so this is okay
 	return ""
 }
 
-func loadTemplateFromRemote(remoteLoc string) (*template.Template, error) {
-	d, _, err := loader.ReadRemoteFile(remoteLoc)
+func loadTemplateFromRemote(ctx context.Context, remoteLoc string) (*template.Template, error) {
+	d, _, err := loader.GetRemoteDataContext(ctx, remoteLoc)
 	if err != nil {
-		log.Error().Err(err).Msgf("could not read remote template file: %s", remoteLoc)
+		log.Ctx(ctx).Error().Err(err).Msgf("could not read remote template file: %s", remoteLoc)
+		return nil, err
 	}
-	log.Debug().Msgf("remote template read completed for: %s", remoteLoc)
+	log.Ctx(ctx).Debug().Msgf("remote template read completed for: %s", remoteLoc)
 	t := template.New(path.Base(remoteLoc))
 	t = t.Funcs(templateFuncs)
 	return t.Parse(string(d))
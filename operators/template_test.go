@@ -0,0 +1,48 @@
+package operators
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateDryRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		existing   string
+		perms      os.FileMode
+		wantStatus Status
+	}{
+		{name: "new file", perms: 0600, wantStatus: StatusChanged},
+		{name: "content change", existing: "old", wantStatus: StatusChanged},
+		{name: "mode change", existing: "content", perms: 0600, wantStatus: StatusChanged},
+		{name: "unchanged", existing: "content", perms: 0644, wantStatus: StatusOk},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src, dest := filepath.Join(dir, "app.tmpl"), filepath.Join(dir, "app.conf")
+			if err := os.WriteFile(src, []byte("content"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.existing) > 0 {
+				if err := os.WriteFile(dest, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			SetDryRun(true)
+			defer SetDryRun(false)
+			res, err := (&Template{Template: dest, RemoteLoc: src, Perms: tt.perms}).Execute(context.Background())
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if res.Status != tt.wantStatus {
+				t.Errorf("Execute() status = %s, want %s (%s)", res.Status, tt.wantStatus, res.Reason)
+			}
+			if fi, err := os.Stat(dest); len(tt.existing) > 0 && (err != nil || fi.Mode().Perm() != 0644) {
+				t.Errorf("Execute() changed the file during a dry run")
+			}
+		})
+	}
+}
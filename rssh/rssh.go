@@ -2,10 +2,13 @@ package rssh
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/rs/zerolog/log"
+	"net"
 	"os"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
@@ -20,6 +23,11 @@ type RSSH struct {
 }
 
 func NewRSSH(host, user, privkey string) (*RSSH, error) {
+	return NewRSSHContext(context.Background(), host, user, privkey)
+}
+
+// NewRSSHContext is NewRSSH where connecting to the host is abandoned once ctx is done.
+func NewRSSHContext(ctx context.Context, host, user, privkey string) (*RSSH, error) {
 	if privkey == "" {
 		privkey = os.ExpandEnv("$HOME/.ssh/id_rsa")
 	}
@@ -40,14 +48,14 @@ func NewRSSH(host, user, privkey string) (*RSSH, error) {
 		Key:  keyBytes,
 		Port: port,
 	}
-	err = rsshc.setup()
+	err = rsshc.setup(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return rsshc, nil
 }
 
-func (r *RSSH) setup() error {
+func (r *RSSH) setup(ctx context.Context) error {
 	signer, err := ssh.ParsePrivateKey(r.Key)
 	if err != nil {
 		return fmt.Errorf("failed to parse private key: %w", err)
@@ -68,26 +76,49 @@ func (r *RSSH) setup() error {
 	}
 
 	addr := fmt.Sprintf("%s%s", r.Host, r.Port)
-	client, err := ssh.Dial("tcp", addr, conf)
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to host %s: %w", addr, err)
+	}
+	// the deadline of ctx also bounds the handshake, it is cleared once connected
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, conf)
 	if err != nil {
+		conn.Close()
 		return fmt.Errorf("failed to connect to host %s: %w", addr, err)
 	}
-	r.client = client
+	conn.SetDeadline(time.Time{})
+	r.client = ssh.NewClient(c, chans, reqs)
 	return nil
 }
 
 func (r *RSSH) ExecCommand(cmd string) (string, error) {
+	return r.ExecCommandContext(context.Background(), cmd)
+}
+
+// ExecCommandContext is ExecCommand where the remote command is killed and ctx.Err() returned once ctx is done.
+func (r *RSSH) ExecCommandContext(ctx context.Context, cmd string) (string, error) {
 	session, err := r.client.NewSession()
 	if err != nil {
 		return "", fmt.Errorf("failed to create session: %w", err)
 	}
-	defer log.Error().Err(session.Close())
+	defer session.Close()
 
 	var stdout, stderr bytes.Buffer
 	session.Stdout = &stdout
 	session.Stderr = &stderr
 
-	if err := session.Run(cmd); err != nil {
+	done := make(chan error, 1)
+	go func() { done <- session.Run(cmd) }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		log.Error().Err(session.Signal(ssh.SIGKILL))
+		return "", ctx.Err()
+	}
+	if err != nil {
 		return "", fmt.Errorf("command execution failed: %w | stderr: %s", err, stderr.String())
 	}
 
@@ -19,6 +19,18 @@ func GetLinuxPackageHandler() string {
 		log.Debug().Msg("using apt package handler")
 		return "/usr/bin/apt"
 	}
+	if _, err := os.Stat("/sbin/apk"); !os.IsNotExist(err) {
+		log.Debug().Msg("using apk package handler")
+		return "/sbin/apk"
+	}
+	if _, err := os.Stat("/usr/bin/pacman"); !os.IsNotExist(err) {
+		log.Debug().Msg("using pacman package handler")
+		return "/usr/bin/pacman"
+	}
+	if _, err := os.Stat("/usr/bin/zypper"); !os.IsNotExist(err) {
+		log.Debug().Msg("using zypper package handler")
+		return "/usr/bin/zypper"
+	}
 
 	log.Error().Err(fmt.Errorf("no package handler")).Msg("could not find a supported package handler for this system")
 	return ""
@@ -0,0 +1,22 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// StateDir returns the directory bruce keeps run state in, /var/lib/bruce for root or ~/.bruce for other users.
+// BRUCE_STATE_DIR may be set to override the location.
+func StateDir() string {
+	if d := os.Getenv("BRUCE_STATE_DIR"); d != "" {
+		return d
+	}
+	if os.Geteuid() == 0 {
+		return "/var/lib/bruce"
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "bruce")
+	}
+	return filepath.Join(home, ".bruce")
+}
@@ -5,6 +5,7 @@ import (
 	"bufio"
 	"fmt"
 	"github.com/rs/zerolog/log"
+	"io"
 	"os"
 	"os/user"
 	"path"
@@ -33,7 +34,6 @@ type SystemInfo struct {
 	CanUpdateServices     bool
 	ServiceControllerPath string
 	ServiceController     string
-	ModifiedTemplates     []string
 }
 
 func InitializeSysInfo() error {
@@ -78,32 +78,36 @@ func ReadLinuxOsData(s *SystemInfo) bool {
 			log.Error().Err(err).Msg("could not read /etc/os-release")
 			return false
 		}
-		fs := bufio.NewScanner(readFile)
-		fs.Split(bufio.ScanLines)
-
-		for fs.Scan() {
-			fields := strings.Split(fs.Text(), "=")
-			if fields[0] == "ID" {
-				s.OSID = s.wash(fields[1])
-			}
-			if fields[0] == "VERSION_ID" {
-				s.OSVersionID = s.wash(fields[1])
-			}
-			if fields[0] == "VERSION_CODENAME" {
-				s.OsName = s.wash(fields[1])
-			}
-			if s.OSVersionID != "" {
-				return true
-			}
-			//fmt.Println(fs.Text())
+		defer readFile.Close()
+		if s.parseOsRelease(readFile) {
+			return true
 		}
-
-		readFile.Close()
 	}
 	s.OSArch = s.wash(exe.Run("uname -m", "").Get())
 	return false
 }
 
+// parseOsRelease reads the os-release fields, the whole file is read as the codename usually follows VERSION_ID.
+func (s *SystemInfo) parseOsRelease(r io.Reader) bool {
+	fs := bufio.NewScanner(r)
+	fs.Split(bufio.ScanLines)
+	for fs.Scan() {
+		key, value, ok := strings.Cut(fs.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "ID":
+			s.OSID = s.wash(value)
+		case "VERSION_ID":
+			s.OSVersionID = s.wash(value)
+		case "VERSION_CODENAME":
+			s.OsName = s.wash(value)
+		}
+	}
+	return s.OSVersionID != ""
+}
+
 func (s *SystemInfo) wash(input string) string {
 	bs := strings.ToLower(strings.Trim(input, " "))
 	us, err := strconv.Unquote(bs)
@@ -184,8 +188,3 @@ func (s *SystemInfo) Save() {
 	defer sysLock.Unlock()
 	sys = s
 }
-
-func (s *SystemInfo) AddModifiedTemplate(local string) {
-	s.ModifiedTemplates = append(s.ModifiedTemplates, local)
-	s.Save()
-}
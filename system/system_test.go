@@ -0,0 +1,56 @@
+package system
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOsRelease(t *testing.T) {
+	tests := []struct {
+		name        string
+		release     string
+		wantID      string
+		wantVersion string
+		wantName    string
+		wantOk      bool
+	}{
+		{
+			name:        "debian",
+			release:     "PRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\nNAME=\"Debian GNU/Linux\"\nVERSION_ID=\"12\"\nVERSION=\"12 (bookworm)\"\nVERSION_CODENAME=bookworm\nID=debian\n",
+			wantID:      "debian",
+			wantVersion: "12",
+			wantName:    "bookworm",
+			wantOk:      true,
+		},
+		{
+			name:        "ubuntu",
+			release:     "NAME=\"Ubuntu\"\nVERSION_ID=\"22.04\"\nVERSION=\"22.04.4 LTS (Jammy Jellyfish)\"\nVERSION_CODENAME=jammy\nID=ubuntu\nUBUNTU_CODENAME=jammy\n",
+			wantID:      "ubuntu",
+			wantVersion: "22.04",
+			wantName:    "jammy",
+			wantOk:      true,
+		},
+		{
+			name:        "fedora without codename",
+			release:     "NAME=\"Fedora Linux\"\nID=fedora\nVERSION_ID=40\n",
+			wantID:      "fedora",
+			wantVersion: "40",
+			wantOk:      true,
+		},
+		{
+			name:     "no version",
+			release:  "ID=debian\nVERSION_CODENAME=trixie\n",
+			wantID:   "debian",
+			wantName: "trixie",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SystemInfo{}
+			ok := s.parseOsRelease(strings.NewReader(tt.release))
+			if ok != tt.wantOk || s.OSID != tt.wantID || s.OSVersionID != tt.wantVersion || s.OsName != tt.wantName {
+				t.Errorf("parseOsRelease() = %v %q %q %q, want %v %q %q %q", ok, s.OSID, s.OSVersionID, s.OsName, tt.wantOk, tt.wantID, tt.wantVersion, tt.wantName)
+			}
+		})
+	}
+}
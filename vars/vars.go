@@ -0,0 +1,119 @@
+// Package vars holds the variables of a single run so executions do not share state through the process environment.
+package vars
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type contextKey struct{}
+
+// Scope is a set of variables layered on top of the process environment. A run has a single root scope, each step
+// may add a child scope for its own env vars, variables set from within a step are written to the root scope so they
+// remain available to the following steps of the same run.
+type Scope struct {
+	mu     sync.RWMutex
+	values map[string]string
+	parent *Scope
+}
+
+// New creates a root scope holding a copy of values.
+func New(values map[string]string) *Scope {
+	s := &Scope{values: make(map[string]string)}
+	for k, v := range values {
+		s.values[k] = v
+	}
+	return s
+}
+
+// Child creates a scope where values take precedence over s.
+func (s *Scope) Child(values map[string]string) *Scope {
+	c := New(values)
+	c.parent = s
+	return c
+}
+
+// WithScope returns a copy of ctx carrying s.
+func WithScope(ctx context.Context, s *Scope) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// FromContext returns the scope of ctx, an empty scope over the process environment is returned when there is none.
+func FromContext(ctx context.Context) *Scope {
+	if s, ok := ctx.Value(contextKey{}).(*Scope); ok {
+		return s
+	}
+	return New(nil)
+}
+
+// Lookup returns the value of a variable, falling back to the process environment.
+func (s *Scope) Lookup(key string) (string, bool) {
+	for sc := s; sc != nil; sc = sc.parent {
+		sc.mu.RLock()
+		v, ok := sc.values[key]
+		sc.mu.RUnlock()
+		if ok {
+			return v, true
+		}
+	}
+	return os.LookupEnv(key)
+}
+
+// Get returns the value of a variable or an empty string.
+func (s *Scope) Get(key string) string {
+	v, _ := s.Lookup(key)
+	return v
+}
+
+// Set stores a variable in the root scope, it is also updated in any scope between s and the root which shadows it.
+func (s *Scope) Set(key, value string) {
+	for sc := s; sc != nil; sc = sc.parent {
+		sc.mu.Lock()
+		if _, ok := sc.values[key]; ok || sc.parent == nil {
+			sc.values[key] = value
+		}
+		sc.mu.Unlock()
+	}
+}
+
+// Values returns a copy of the variables held by the scope and its parents, without the process environment.
+func (s *Scope) Values() map[string]string {
+	m := make(map[string]string)
+	if s.parent != nil {
+		m = s.parent.Values()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for k, v := range s.values {
+		m[k] = v
+	}
+	return m
+}
+
+// Map returns the process environment overlaid with the scope variables.
+func (s *Scope) Map() map[string]string {
+	m := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			m[k] = v
+		}
+	}
+	for k, v := range s.Values() {
+		m[k] = v
+	}
+	return m
+}
+
+// Environ returns the scope as a sorted list of key=value pairs for child processes.
+func (s *Scope) Environ() []string {
+	m := s.Map()
+	env := make([]string, 0, len(m))
+	for k, v := range m {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
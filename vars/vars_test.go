@@ -0,0 +1,63 @@
+package vars
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	t.Setenv("BRUCE_VARS_PROCESS", "process")
+	root := New(map[string]string{"RUN": "run", "SHADOWED": "run"})
+	step := root.Child(map[string]string{"STEP": "step", "SHADOWED": "step"})
+	step.Set("EXPORTED", "exported")
+	step.Set("SHADOWED", "updated")
+
+	tests := []struct {
+		name  string
+		scope *Scope
+		key   string
+		want  string
+	}{
+		{name: "process fallback", scope: root, key: "BRUCE_VARS_PROCESS", want: "process"},
+		{name: "root value", scope: step, key: "RUN", want: "run"},
+		{name: "step value", scope: step, key: "STEP", want: "step"},
+		{name: "step value not in root", scope: root, key: "STEP", want: ""},
+		{name: "set from step reaches root", scope: root, key: "EXPORTED", want: "exported"},
+		{name: "set updates shadowing step", scope: step, key: "SHADOWED", want: "updated"},
+		{name: "set updates root", scope: root, key: "SHADOWED", want: "updated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.Get(tt.key); got != tt.want {
+				t.Errorf("Get(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	s := New(map[string]string{"A": "1"})
+	if got := FromContext(WithScope(context.Background(), s)); got != s {
+		t.Errorf("FromContext() did not return the scope of the context")
+	}
+	if got := FromContext(context.Background()); got == nil || len(got.Values()) != 0 {
+		t.Errorf("FromContext() without a scope = %v, want an empty scope", got)
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	t.Setenv("BRUCE_VARS_PROCESS", "process")
+	s := New(map[string]string{"BRUCE_VARS_PROCESS": "run"})
+	found := false
+	for _, kv := range s.Environ() {
+		if kv == "BRUCE_VARS_PROCESS=process" {
+			t.Errorf("Environ() kept the process value of an overridden variable")
+		}
+		if kv == "BRUCE_VARS_PROCESS=run" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Environ() is missing the run value")
+	}
+}